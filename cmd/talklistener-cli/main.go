@@ -1,12 +1,15 @@
 package main
 
 import (
+	"fmt"
+	"log"
 	"os"
 	"strings"
 
 	"github.com/but80/talklistener/internal/generator"
 	"github.com/but80/talklistener/internal/globalopt"
 	"github.com/but80/talklistener/internal/julius"
+	"github.com/but80/talklistener/internal/lint"
 	"github.com/but80/talklistener/internal/vsqx"
 	"github.com/comail/colog"
 	"github.com/urfave/cli"
@@ -17,6 +20,7 @@ var version = "unknown"
 const description = `
    - <音声ファイル> は .wav .aiff .flac 等のフォーマットに対応しています。
      詳細はlibsndfileのプロジェクトページ http://www.mega-nerd.com/libsndfile/ をお読みください。
+   - <音声ファイル> に - を指定すると標準入力から読み込み、VSQXを標準出力に出力します。
    - イントネーションの抽出に「音声分析変換合成システム WORLD」
      https://github.com/mmorise/World を使用しています。
    - 発音タイミングの抽出に「大語彙連続音声認識エンジン Julius」
@@ -39,7 +43,7 @@ func singerList() string {
 	}
 	line = line[:len(line)-2]
 	result += "\n" + line
-	return "   シンガー一覧:" + result
+	return "   シンガー一覧:" + result + "\n\n   シンガー定義ファイルで追加したシンガーは talklistener singers list で確認できます。"
 }
 
 func main() {
@@ -59,25 +63,135 @@ func main() {
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
 			Name:  "singer, s",
-			Usage: "シンガー",
+			Usage: "シンガー（--split-channels 指定時はカンマ区切りでチャンネルごとに指定）",
 			Value: vsqx.DefaultSinger,
 		},
+		cli.StringFlag{
+			Name:  "singers",
+			Usage: "シンガーの定義を追加するJSONファイル（省略時は " + vsqx.DefaultSingersFile() + " があれば使用）",
+		},
+		cli.IntFlag{
+			Name:  "channel",
+			Usage: "指定したチャンネル（1〜）のみを処理します（省略時は全チャンネルを平均）",
+		},
+		cli.BoolFlag{
+			Name:  "split-channels",
+			Usage: "チャンネルごとに別々のトラックを生成します",
+		},
 		cli.IntFlag{
 			Name:  "transpose, t",
 			Usage: `出力VSQX内の全ノートの音高をずらします（単位：セント）`,
 		},
+		cli.StringFlag{
+			Name:  "transpose-to",
+			Usage: `話者の音高の中央値を指定した音名（C3 = 60）またはノート番号に合わせて移調します（singer を指定すると、シンガーの推奨音域を外れる場合のみ推奨音域の中央に合わせます）`,
+		},
+		cli.BoolFlag{
+			Name:  "transpose-octave",
+			Usage: `--transpose-to による移調をオクターブ単位で行います`,
+		},
 		cli.BoolFlag{
 			Name:  "split-consonant, c",
 			Usage: `子音を母音とは別のノートに分割配置します`,
 		},
+		cli.StringFlag{
+			Name:  "profile",
+			Usage: "ノートの生成規則を記述したJSONファイル、またはプリセット名 (" + strings.Join(generator.ProfilePresets(), ", ") + ")",
+		},
+		cli.StringFlag{
+			Name:  "devoice",
+			Usage: "無声化した母音（です・ました等の /i/ /u/）を検出し、指定した方法で出力します (" + strings.Join(generator.DevoiceModes, ", ") + ")",
+		},
+		cli.StringFlag{
+			Name:  "long-vowel",
+			Usage: "長音の出力方法 (" + strings.Join(generator.LongVowelModes, ", ") + ")",
+			Value: "extend",
+		},
+		cli.StringFlag{
+			Name:  "gen",
+			Usage: "話者の声質からGENを推定し、指定した方法で出力します (" + strings.Join(generator.GENModes, ", ") + ")",
+		},
+		cli.StringFlag{
+			Name:  "gen-reference",
+			Usage: "GENの推定に用いる基準の音声ファイル、スペクトル包絡の重心周波数（単位：Hz）、または " + generator.GENReferenceSinger + "（シンガーの推奨音域から見積もります）",
+		},
+		cli.BoolFlag{
+			Name:  "vibrato",
+			Usage: "持続音のビブラートを検出し、ピッチベンドではなくノートのビブラートとして出力します",
+		},
+		cli.Float64Flag{
+			Name:  "highpass",
+			Usage: "前処理: 直流成分と低域雑音を除去するハイパスフィルタのカットオフ周波数（単位：Hz, 0 でフィルタなし）",
+		},
+		cli.Float64Flag{
+			Name:  "denoise",
+			Usage: "前処理: スペクトル減算による定常雑音除去の強さ（0 で除去なし, 1〜2 程度を推奨）",
+		},
+		cli.Float64Flag{
+			Name:  "noise-gate",
+			Usage: "前処理: 音量が指定値を下回る区間を無音にするノイズゲートの閾値（単位：dBFS, 0 でゲートなし）",
+		},
+		cli.StringFlag{
+			Name:  "normalize",
+			Usage: "前処理: 音量の正規化方法 (" + strings.Join(generator.NormalizeModes, ", ") + ")",
+		},
+		cli.Float64Flag{
+			Name:  "normalize-level",
+			Usage: "前処理: 正規化後の音量（単位：peak は dBFS, lufs は LUFS, 0 で peak: -1, lufs: -23）",
+		},
+		cli.Float64Flag{
+			Name:  "chunk",
+			Usage: "長い音声を無音区間で分割して処理する際の、区間の最大長（単位：秒, 0 で分割なし）",
+		},
+		cli.StringFlag{
+			Name:  "part-split",
+			Usage: "出力VSQXのパートを、長い無音区間またはテキストファイルの行ごとに分割します (" + strings.Join(generator.PartSplitModes, ", ") + ")",
+		},
+		cli.Float64Flag{
+			Name:  "part-pause",
+			Usage: "--part-split pause 指定時に、パートを分割する無音区間の最短長（単位：秒）",
+			Value: 1.0,
+		},
 		cli.BoolFlag{
 			Name:  "redictate, R",
 			Usage: "発話内容の再認識を行い、その結果をテキストファイルに上書き保存します",
 		},
-		cli.StringFlag{
+		cli.Float64Flag{
 			Name:  "f0-cutoff, f",
-			Usage: "基本周波数の変動にかけるLPFのカットオフ周波数 (" + strings.Join(generator.FIRLPFCutoffs, ", ") + ")",
-			Value: "1.5",
+			Usage: "基本周波数の変動にかけるLPFのカットオフ周波数（単位：Hz, 0 でフィルタなし）",
+			Value: 7.5,
+		},
+		cli.IntFlag{
+			Name:  "f0-taps",
+			Usage: "基本周波数の変動にかけるLPFのタップ数",
+			Value: 221,
+		},
+		cli.StringFlag{
+			Name:  "f0-window",
+			Usage: "基本周波数の変動にかけるLPFの設計に用いる窓関数 (" + strings.Join(generator.FIRWindows, ", ") + ")",
+			Value: "hamming",
+		},
+		cli.StringFlag{
+			Name:  "f0-filter",
+			Usage: "基本周波数の変動にかけるLPFの方式 (" + strings.Join(generator.F0FilterModes, ", ") + ")",
+			Value: "zerophase",
+		},
+		cli.BoolFlag{
+			Name:  "f0-octave-fix",
+			Usage: "基本周波数のオクターブエラー（前後から1オクターブ程度跳んだ箇所）を補正します",
+		},
+		cli.BoolFlag{
+			Name:  "f0-despike",
+			Usage: "基本周波数の短い有声区間や前後から大きく外れた箇所を除去します",
+		},
+		cli.IntFlag{
+			Name:  "f0-median",
+			Usage: "基本周波数に指定した幅（単位：フレーム = 5ミリ秒）のメディアンフィルタをかけます（0 でフィルタなし）",
+		},
+		cli.StringFlag{
+			Name:  "f0-interpolate",
+			Usage: "無声区間の基本周波数の補間方法 (" + strings.Join(generator.InterpolateModes, ", ") + ")",
+			Value: "hold",
 		},
 		cli.Float64Flag{
 			Name:  "f0-delay, d",
@@ -91,12 +205,32 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:  "out",
-			Usage: `出力VSQXを指定した名前で保存します（省略時は "音声ファイル名.vsqx"）`,
+			Usage: `出力VSQXを指定した名前で保存します（省略時は "音声ファイル名.vsqx", - で標準出力）`,
+		},
+		cli.StringFlag{
+			Name:  "textgrid",
+			Usage: "音素と行ごとの発音区間を、指定した名前のTextGridファイルに保存します",
+		},
+		cli.StringFlag{
+			Name:  "srt",
+			Usage: "行ごとの発音区間を、指定した名前のSRT字幕ファイルに保存します",
 		},
 		cli.StringFlag{
 			Name:  "text",
 			Usage: `テキストファイルを指定した名前で保存・ロードします（省略時は "音声ファイル名.txt"）`,
 		},
+		cli.StringFlag{
+			Name:  "dict",
+			Usage: "テキストファイル中の漢字等の表記を読みに置換するユーザー辞書ファイル",
+		},
+		cli.BoolFlag{
+			Name:  "learn-dict",
+			Usage: "発話内容の認識結果に含まれる漢字等の表記の読みを、ユーザー辞書に追記します",
+		},
+		cli.StringFlag{
+			Name:  "cache-dir",
+			Usage: `キャッシュ "音声ファイル名.tlo/" を作成するディレクトリ（省略時は音声ファイルと同じ場所, 標準入力の場合は一時ディレクトリ）`,
+		},
 		cli.BoolFlag{
 			Name:  "recache, r",
 			Usage: `キャッシュ "音声ファイル名.tlo/" を再作成します`,
@@ -142,23 +276,197 @@ func main() {
 			colog.SetMinLevel(colog.LInfo)
 		}
 		if err := generator.Generate(&generator.GenerateOptions{
-			AudioFile:      wavfile,
-			TextFile:       txtfile,
-			OutFile:        outfile,
-			Singer:         ctx.String("singer"),
-			F0LPFCutoff:    ctx.String("f0-cutoff"),
-			F0Delay:        ctx.Float64("f0-delay") * .001,
-			DictationModel: ctx.String("dictation-model"),
-			SplitConsonant: ctx.Bool("split-consonant"),
-			Transpose:      ctx.Int("transpose"),
-			Redictate:      ctx.Bool("redictate"),
-			Recache:        ctx.Bool("recache"),
+			AudioFile:       wavfile,
+			TextFile:        txtfile,
+			OutFile:         outfile,
+			TextGridFile:    ctx.String("textgrid"),
+			SRTFile:         ctx.String("srt"),
+			CacheDir:        ctx.String("cache-dir"),
+			Singer:          ctx.String("singer"),
+			SingersFile:     ctx.String("singers"),
+			Channel:         ctx.Int("channel"),
+			SplitChannels:   ctx.Bool("split-channels"),
+			F0LPFCutoff:     ctx.Float64("f0-cutoff"),
+			F0LPFTaps:       ctx.Int("f0-taps"),
+			F0LPFWindow:     ctx.String("f0-window"),
+			F0Filter:        ctx.String("f0-filter"),
+			F0Delay:         ctx.Float64("f0-delay") * .001,
+			DictationModel:  ctx.String("dictation-model"),
+			SplitConsonant:  ctx.Bool("split-consonant"),
+			LongVowel:       ctx.String("long-vowel"),
+			Devoice:         ctx.String("devoice"),
+			Profile:         ctx.String("profile"),
+			Transpose:       ctx.Int("transpose"),
+			TransposeTo:     ctx.String("transpose-to"),
+			TransposeOctave: ctx.Bool("transpose-octave"),
+			GENMode:         ctx.String("gen"),
+			GENReference:    ctx.String("gen-reference"),
+			Vibrato:         ctx.Bool("vibrato"),
+			ChunkLength:     ctx.Float64("chunk"),
+			PartSplit:       ctx.String("part-split"),
+			PartPause:       ctx.Float64("part-pause"),
+			UserDict:        ctx.String("dict"),
+			LearnDict:       ctx.Bool("learn-dict"),
+			Redictate:       ctx.Bool("redictate"),
+			Recache:         ctx.Bool("recache"),
+			F0Cleanup: generator.F0CleanupOptions{
+				OctaveFix:   ctx.Bool("f0-octave-fix"),
+				Despike:     ctx.Bool("f0-despike"),
+				Median:      ctx.Int("f0-median"),
+				Interpolate: ctx.String("f0-interpolate"),
+			},
+			Preprocess: generator.PreprocessOptions{
+				HighPass:       ctx.Float64("highpass"),
+				NoiseGate:      ctx.Float64("noise-gate"),
+				Denoise:        ctx.Float64("denoise"),
+				Normalize:      ctx.String("normalize"),
+				NormalizeLevel: ctx.Float64("normalize-level"),
+			},
 		}); err != nil {
 			return cli.NewExitError(err, 1)
 		}
 		return nil
 	}
 
+	app.Commands = []cli.Command{
+		{
+			Name:      "lint",
+			Usage:     "テキストファイルの読みを検査し、問題のある箇所を行・桁とともに表示します",
+			ArgsUsage: "<テキストファイル>",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dict",
+					Usage: "テキストファイル中の漢字等の表記を読みに置換するユーザー辞書ファイル",
+				},
+			},
+			Action: lintAction,
+		},
+		{
+			Name:  "singers",
+			Usage: "シンガーの定義を表示・追加します",
+			Subcommands: []cli.Command{
+				{
+					Name:  "list",
+					Usage: "定義されているシンガーの一覧を表示します",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "singers",
+							Usage: "シンガーの定義を追加するJSONファイル（省略時は " + vsqx.DefaultSingersFile() + " があれば使用）",
+						},
+					},
+					Action: singersListAction,
+				},
+				{
+					Name:      "import",
+					Usage:     "VSQXファイルで使用されているシンガーの定義（compID, vBS, 名前）をシンガー定義ファイルに追加します",
+					ArgsUsage: "<VSQXファイル...>",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "out, o",
+							Usage: "追加先のシンガー定義ファイル",
+							Value: vsqx.DefaultSingersFile(),
+						},
+					},
+					Action: singersImportAction,
+				},
+			},
+		},
+	}
+
 	colog.Register()
 	app.Run(os.Args)
 }
+
+func lintAction(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(ctx, "lint", 1)
+	}
+	colog.SetMinLevel(colog.LInfo)
+	var dict *julius.UserDictionary
+	if filename := ctx.String("dict"); filename != "" {
+		var err error
+		dict, err = julius.LoadUserDictionary(filename)
+		if err != nil {
+			return cli.NewExitError(err, 1)
+		}
+	}
+	errorCount := 0
+	for _, txtfile := range ctx.Args() {
+		diags, err := lint.Lint(txtfile, dict)
+		if err != nil {
+			return cli.NewExitError(err, 1)
+		}
+		for _, d := range diags {
+			fmt.Printf("%s:%s\n", txtfile, d)
+			if d.Severity == lint.Error {
+				errorCount++
+			}
+		}
+		log.Printf("info: %s: %d 件の問題が見つかりました", txtfile, len(diags))
+	}
+	if 0 < errorCount {
+		return cli.NewExitError("", 1)
+	}
+	return nil
+}
+
+func singersListAction(ctx *cli.Context) error {
+	colog.SetMinLevel(colog.LInfo)
+	if err := generator.LoadSingers(ctx.String("singers")); err != nil {
+		return cli.NewExitError(fmt.Sprintf("シンガー定義ファイルの読み込みに失敗しました: %s", err), 1)
+	}
+	for _, s := range vsqx.Singers() {
+		line := s
+		if vsqx.IsEnglishSinger(s) {
+			line += "\t英語"
+		} else {
+			line += "\t日本語"
+		}
+		if r, ok := vsqx.SingerRange(s); ok {
+			line += fmt.Sprintf("\t推奨音域: %d〜%d", r.Low, r.High)
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func singersImportAction(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(ctx, "import", 1)
+	}
+	colog.SetMinLevel(colog.LInfo)
+	out := ctx.String("out")
+	if out == "" {
+		return cli.NewExitError("追加先のシンガー定義ファイルを指定してください", 1)
+	}
+	singers := map[string]*vsqx.SingerConfig{}
+	if _, err := os.Stat(out); err == nil {
+		singers, err = vsqx.ReadSingers(out)
+		if err != nil {
+			return cli.NewExitError(err, 1)
+		}
+	}
+	count := 0
+	for _, vsqxfile := range ctx.Args() {
+		imported, err := vsqx.ImportSingers(vsqxfile)
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("%s: %s", vsqxfile, err), 1)
+		}
+		for name, c := range imported {
+			if old, ok := singers[name]; ok {
+				// 推奨音域等の設定は残す
+				old.CompID = c.CompID
+				old.BS = c.BS
+			} else {
+				singers[name] = c
+			}
+			log.Printf("info: %s: シンガー %s (compID: %s, vBS: %d)", vsqxfile, name, c.CompID, c.BS)
+			count++
+		}
+	}
+	if err := vsqx.WriteSingers(out, singers); err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	log.Printf("info: %d 件のシンガーの定義を %s に保存しました", count, out)
+	return nil
+}
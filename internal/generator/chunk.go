@@ -0,0 +1,215 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/but80/talklistener/internal/julius"
+	"github.com/mkb218/gosndfile/sndfile"
+	"golang.org/x/xerrors"
+)
+
+const (
+	vadFrameTime       = .010 // 音量を測る区間長（秒）
+	vadMinSilence      = .3   // 分割位置とみなす無音区間の最短長（秒）
+	vadNoiseFloorRatio = .1   // 背景雑音とみなす、音量の小さいフレームの割合
+	vadMargin          = 10.0 // 背景雑音に対する、無音と判定する音量の上限（dB）
+	vadSpeechRatio     = .9   // 発話の音量とみなすパーセンタイル
+	emptyChunkText     = "sp" // 発話が認識されなかった区間のテキスト
+)
+
+// chunk は、音声を分割した区間（サンプル位置）です。
+type chunk struct {
+	begin int
+	end   int
+}
+
+func (c chunk) beginTime(fs int) float64 {
+	return float64(c.begin) / float64(fs)
+}
+
+func (c chunk) endTime(fs int) float64 {
+	return float64(c.end) / float64(fs)
+}
+
+// detectSilences は、短時間の音量から無音区間を検出します。
+func detectSilences(x []float64, fs int) []chunk {
+	frame := int(vadFrameTime * float64(fs))
+	n := len(x) / frame
+	if n == 0 {
+		return nil
+	}
+	levels := make([]float64, n)
+	for i := range levels {
+		sum := .0
+		for _, v := range x[i*frame : (i+1)*frame] {
+			sum += v * v
+		}
+		levels[i] = 10.0 * math.Log10(sum/float64(frame)+1e-12)
+	}
+	sorted := append([]float64{}, levels...)
+	sort.Float64s(sorted)
+	threshold := sorted[int(float64(n-1)*vadNoiseFloorRatio)] + vadMargin
+	// 無音区間が少なく背景雑音を推定できない場合に、発話を無音とみなさないようにする
+	if speech := sorted[int(float64(n-1)*vadSpeechRatio)] - vadMargin; speech < threshold {
+		threshold = speech
+	}
+	log.Printf("debug: VAD threshold = %.1f dB", threshold)
+
+	minFrames := int(vadMinSilence / vadFrameTime)
+	result := []chunk{}
+	begin := -1
+	for i := 0; i <= n; i++ {
+		if i < n && levels[i] < threshold {
+			if begin < 0 {
+				begin = i
+			}
+			continue
+		}
+		if 0 <= begin && minFrames <= i-begin {
+			result = append(result, chunk{begin: begin * frame, end: i * frame})
+		}
+		begin = -1
+	}
+	return result
+}
+
+// splitChunks は、無音区間の中央で音声を分割し、各区間がなるべく maxLength 秒以下になるようにします。
+// 分割位置は、発音タイミングのフレーム番号をずらせるよう vadFrameTime の整数倍に揃えます。
+func splitChunks(x []float64, fs int, maxLength float64) []chunk {
+	maxSamples := int(maxLength * float64(fs))
+	frame := int(vadFrameTime * float64(fs))
+	result := []chunk{}
+	begin := 0
+	best := -1
+	for _, s := range detectSilences(x, fs) {
+		pos := (s.begin + s.end) / 2
+		pos -= pos % frame
+		if maxSamples < pos-begin {
+			// 最大長以内に無音区間がない場合は、最初に見つかった無音区間で分割する
+			cut := best
+			if cut < 0 {
+				cut = pos
+			}
+			result = append(result, chunk{begin: begin, end: cut})
+			begin = cut
+			best = -1
+			if pos == begin {
+				continue
+			}
+		}
+		best = pos
+	}
+	if maxSamples < len(x)-begin && 0 <= best {
+		result = append(result, chunk{begin: begin, end: best})
+		begin = best
+	}
+	return append(result, chunk{begin: begin, end: len(x)})
+}
+
+var blankLinesRx = regexp.MustCompile(`\n[ \t\r]*\n\s*`)
+
+// splitText は、空行で区切られた段落ごとにテキストを分割します。
+func splitText(text string) []string {
+	text = strings.TrimSpace(strings.Replace(text, "\r\n", "\n", -1))
+	if text == "" {
+		return nil
+	}
+	return blankLinesRx.Split(text, -1)
+}
+
+// recognizeChunks は、音声を無音区間で分割し、区間ごとに発話内容と発音タイミングを推定します。
+// 推定結果のセグメントの時刻は、音声全体の先頭からの時刻に補正されます。
+// テキストファイルには、区間ごとの発話内容を空行で区切って記述します。
+func recognizeChunks(opts *GenerateOptions, dict *julius.UserDictionary, wavFile, textFile, objPrefix string) (*julius.Result, []chunk, error) {
+	x, fs, err := loadWav(wavFile)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("音声ファイルの読み込みに失敗しました: %w", err)
+	}
+	chunks := splitChunks(x, fs, opts.ChunkLength)
+	log.Printf("info: 音声を %d 個の区間に分割しました", len(chunks))
+	for i, c := range chunks {
+		log.Printf("info: 区間 %d: %s 〜 %s", i+1, srtTime(c.beginTime(fs)), srtTime(c.endTime(fs)))
+	}
+
+	prefixes := make([]string, len(chunks))
+	for i, c := range chunks {
+		prefixes[i] = fmt.Sprintf("%s.part%d", objPrefix, i+1)
+		if err := writeWav(prefixes[i]+".wav", x[c.begin:c.end], fs, sndfile.SF_FORMAT_PCM_16); err != nil {
+			return nil, nil, xerrors.Errorf("分割した音声の保存に失敗しました: %w", err)
+		}
+	}
+
+	julius.OnProgress = nil
+	if isEmpty(textFile) || opts.Redictate {
+		texts := make([]string, len(chunks))
+		dictations := make([]*julius.Result, len(chunks))
+		for i := range chunks {
+			log.Printf("info: 区間 %d / %d の発話内容を推定中...", i+1, len(chunks))
+			result, err := julius.Dictate(prefixes[i]+".wav", opts.DictationModel)
+			if err != nil {
+				return nil, nil, xerrors.Errorf("発話内容の推定に失敗しました: %w", err)
+			}
+			dictations[i] = result
+			texts[i] = strings.TrimSpace(result.DictationString())
+			if texts[i] == "" {
+				texts[i] = emptyChunkText
+			}
+		}
+		if err := ioutil.WriteFile(textFile, []byte(strings.Join(texts, "\n\n")+"\n"), 0644); err != nil {
+			return nil, nil, xerrors.Errorf("推定した発話内容の保存に失敗しました: %w", err)
+		}
+		for _, result := range dictations {
+			if err := learnDictionary(opts, dict, result); err != nil {
+				return nil, nil, err
+			}
+		}
+	} else {
+		log.Print("info: 発話内容をテキストファイルから読み込みます")
+	}
+
+	if err := julius.CheckTranscript(textFile, dict); err != nil {
+		return nil, nil, xerrors.Errorf("テキストファイルの内容が不正です: %w", err)
+	}
+	b, err := ioutil.ReadFile(textFile)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("テキストファイルの読み込みに失敗しました: %w", err)
+	}
+	texts := splitText(string(b))
+	if len(texts) != len(chunks) {
+		return nil, nil, fmt.Errorf("テキストファイルの段落数 (%d) が音声の区間数 (%d) と一致しません。上記の区間ごとの発話内容を空行で区切って記述してください", len(texts), len(chunks))
+	}
+
+	results := make([]*julius.Result, len(chunks))
+	for i := range chunks {
+		if err := ioutil.WriteFile(prefixes[i]+".txt", []byte(texts[i]+"\n"), 0644); err != nil {
+			return nil, nil, xerrors.Errorf("区間 %d のテキストファイルの保存に失敗しました: %w", i+1, err)
+		}
+		log.Printf("info: 区間 %d / %d の発音タイミングを推定中...", i+1, len(chunks))
+		result, err := julius.Segmentate(prefixes[i]+".wav", prefixes[i]+".txt", prefixes[i], dict)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("発音タイミングの推定に失敗しました: 区間 %d: %w", i+1, err)
+		}
+		results[i] = result
+	}
+
+	merged := &julius.Result{}
+	for i, result := range results {
+		offset := chunks[i].beginTime(fs)
+		for _, seg := range result.Segments {
+			seg.Shift(offset)
+			if 0 <= seg.Line {
+				seg.Line += len(merged.Lines)
+			}
+			merged.Segments = append(merged.Segments, seg)
+		}
+		merged.Dictation = append(merged.Dictation, result.Dictation...)
+		merged.Lines = append(merged.Lines, result.Lines...)
+	}
+	return merged, chunks, nil
+}
@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// testSignal は、有声区間（正弦波）と無音区間を交互に並べた音声を生成します。
+// durations は各区間の長さ（秒）で、偶数番目が有声区間、奇数番目が無音区間です。
+func testSignal(fs int, durations ...float64) []float64 {
+	x := []float64{}
+	for i, d := range durations {
+		n := int(d * float64(fs))
+		for j := 0; j < n; j++ {
+			v := .0
+			if i%2 == 0 {
+				v = .5 * math.Sin(2.0*math.Pi*220.0*float64(j)/float64(fs))
+			}
+			x = append(x, v)
+		}
+	}
+	return x
+}
+
+func TestSplitChunks(t *testing.T) {
+	const fs = 8000
+	sec := func(t float64) int { return int(t * fs) }
+	tests := []struct {
+		name      string
+		durations []float64
+		maxLength float64
+		want      []chunk
+	}{
+		{
+			name:      "no silence",
+			durations: []float64{20.0},
+			maxLength: 10.0,
+			want:      []chunk{{0, sec(20.0)}},
+		},
+		{
+			name:      "shorter than max",
+			durations: []float64{10.0, 1.0, 10.0},
+			maxLength: 30.0,
+			want:      []chunk{{0, sec(21.0)}},
+		},
+		{
+			name:      "split at every silence",
+			durations: []float64{10.0, 1.0, 10.0, 1.0, 10.0},
+			maxLength: 15.0,
+			want:      []chunk{{0, sec(10.5)}, {sec(10.5), sec(21.5)}, {sec(21.5), sec(32.0)}},
+		},
+		{
+			name:      "split at last silence within max",
+			durations: []float64{10.0, 1.0, 10.0, 1.0, 10.0},
+			maxLength: 25.0,
+			want:      []chunk{{0, sec(21.5)}, {sec(21.5), sec(32.0)}},
+		},
+		{
+			name:      "no silence within max",
+			durations: []float64{20.0, 1.0, 5.0},
+			maxLength: 10.0,
+			want:      []chunk{{0, sec(20.5)}, {sec(20.5), sec(26.0)}},
+		},
+		{
+			name:      "short pause is ignored",
+			durations: []float64{10.0, .2, 10.0, 1.0, 10.0},
+			maxLength: 15.0,
+			want:      []chunk{{0, sec(20.7)}, {sec(20.7), sec(31.2)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitChunks(testSignal(fs, tt.durations...), fs, tt.maxLength)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitChunks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package generator
+
+import (
+	"math"
+
+	"github.com/but80/talklistener/internal/julius"
+)
+
+const (
+	devoicedMaxVoicedRatio = .3  // 無声化したとみなす、母音区間中の有声フレームの割合の上限
+	devoicedMaxLength      = .15 // 無声化したとみなす母音区間の最大長（秒）
+)
+
+// DevoiceModes は、無声化した母音の出力方法として指定可能な値の一覧です。
+// "phoneme" は無声化した母音の発音記号で、"consonant" は子音のみのノートとして出力します。
+var DevoiceModes = []string{
+	"phoneme",
+	"consonant",
+}
+
+// voicelessConsonants は、後続の母音が無声化しうる無声子音の一覧です。
+var voicelessConsonants = map[string]bool{
+	"k": true, "ky": true, "s": true, "sh": true, "t": true, "ts": true,
+	"ch": true, "h": true, "hy": true, "f": true, "p": true, "py": true,
+}
+
+// devoicedFollowers は、無声子音以外で、後続すると母音が無声化しうる音素の一覧です。
+var devoicedFollowers = map[string]bool{
+	"q": true, "sp": true, "silE": true,
+}
+
+// voicedRatio は、begin〜end 秒の区間で基本周波数が推定されたフレームの割合を返します。
+func voicedRatio(f0 []float64, begin, end, framePeriod float64) float64 {
+	b := int(math.Round(begin / framePeriod))
+	e := int(math.Round(end / framePeriod))
+	if len(f0) < e {
+		e = len(f0)
+	}
+	if e <= b {
+		return 1.0
+	}
+	n := 0
+	for _, f := range f0[b:e] {
+		if minFreq <= f {
+			n++
+		}
+	}
+	return float64(n) / float64(e-b)
+}
+
+// detectDevoiced は、無声子音に挟まれた短い /i/ /u/ のうち、基本周波数がほとんど推定されない
+// （声帯振動を伴わない）ものを無声化した母音として検出し、そのセグメントの位置を返します。
+// f0 は無声区間を補間していない基本周波数（Hz）です。
+func detectDevoiced(segs []julius.Segment, f0 []float64, framePeriod float64) map[int]bool {
+	result := map[int]bool{}
+	for i := 1; i < len(segs); i++ {
+		seg := segs[i]
+		if seg.Unit != "i" && seg.Unit != "u" {
+			continue
+		}
+		if !voicelessConsonants[segs[i-1].Unit] {
+			continue
+		}
+		if i+1 < len(segs) && !voicelessConsonants[segs[i+1].Unit] && !devoicedFollowers[segs[i+1].Unit] {
+			continue
+		}
+		if devoicedMaxLength < seg.EndTime-seg.BeginTime {
+			continue
+		}
+		if voicedRatio(f0, seg.BeginTime, seg.EndTime, framePeriod) <= devoicedMaxVoicedRatio {
+			result[i] = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/but80/talklistener/internal/julius"
+)
+
+type testPhone struct {
+	unit     string
+	length   float64 // 秒
+	voiced   float64 // 基本周波数が推定されるフレームの割合
+	devoiced bool    // 無声化した母音として検出されるべきか
+}
+
+func TestDetectDevoiced(t *testing.T) {
+	const framePeriod = .005
+	vowel := func(unit string, voiced float64, devoiced bool) testPhone {
+		return testPhone{unit: unit, length: .08, voiced: voiced, devoiced: devoiced}
+	}
+	cons := func(unit string) testPhone {
+		return testPhone{unit: unit, length: .06}
+	}
+	tests := []struct {
+		name   string
+		phones []testPhone
+	}{
+		{"desu + pause", []testPhone{cons("d"), vowel("e", 1, false), cons("s"), vowel("u", 0, true), cons("sp")}},
+		{"voiced desu", []testPhone{cons("d"), vowel("e", 1, false), cons("s"), vowel("u", 1, false), cons("sp")}},
+		{"kita", []testPhone{cons("k"), vowel("i", 0, true), cons("t"), vowel("a", 1, false)}},
+		{"kida", []testPhone{cons("k"), vowel("i", 0, false), cons("d"), vowel("a", 1, false)}},
+		{"gita", []testPhone{cons("g"), vowel("i", 0, false), cons("t"), vowel("a", 1, false)}},
+		{"shiq", []testPhone{cons("sh"), vowel("i", 0, true), cons("q"), cons("t"), vowel("a", 1, false)}},
+		{"su at end", []testPhone{cons("s"), vowel("u", 0, true)}},
+		{"partly voiced", []testPhone{cons("k"), vowel("u", .25, true), cons("s"), vowel("a", 1, false)}},
+		{"mostly voiced", []testPhone{cons("k"), vowel("u", .5, false), cons("s"), vowel("a", 1, false)}},
+		{"kat", []testPhone{cons("k"), vowel("a", 0, false), cons("t")}},
+		{"vowel at start", []testPhone{vowel("u", 0, false), cons("s")}},
+		{"long vowel", []testPhone{cons("s"), {unit: "u", length: .2}, cons("sp")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segs := []julius.Segment{}
+			f0 := []float64{}
+			want := map[int]bool{}
+			tm := .0
+			for i, p := range tt.phones {
+				segs = append(segs, julius.Segment{BeginTime: tm, EndTime: tm + p.length, Unit: p.unit})
+				n := int(p.length / framePeriod)
+				for k := 0; k < n; k++ {
+					f := .0
+					if float64(k) < p.voiced*float64(n) {
+						f = 200.0
+					}
+					f0 = append(f0, f)
+				}
+				tm += float64(n) * framePeriod
+				segs[i].EndTime = tm
+				if p.devoiced {
+					want[i] = true
+				}
+			}
+			if got := detectDevoiced(segs, f0, framePeriod); !reflect.DeepEqual(got, want) {
+				t.Errorf("detectDevoiced() = %v, want %v", got, want)
+			}
+		})
+	}
+}
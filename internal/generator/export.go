@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+
+	"github.com/but80/talklistener/internal/julius"
+)
+
+// interval は、TextGrid の区間です。
+type interval struct {
+	begin float64
+	end   float64
+	text  string
+}
+
+// fillIntervals は、区間の重なりを後続の区間の開始位置で切り詰め、隙間を空の区間で埋めます。
+func fillIntervals(src []interval, xmax float64) []interval {
+	result := []interval{}
+	t := .0
+	for i, v := range src {
+		if i+1 < len(src) && src[i+1].begin < v.end {
+			v.end = src[i+1].begin
+		}
+		if v.begin < t {
+			v.begin = t
+		}
+		if v.end <= v.begin {
+			continue
+		}
+		if t < v.begin {
+			result = append(result, interval{begin: t, end: v.begin})
+		}
+		result = append(result, v)
+		t = v.end
+	}
+	if t < xmax {
+		result = append(result, interval{begin: t, end: xmax})
+	}
+	return result
+}
+
+func textGridString(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// writeTextGrid は、音素と行の発音区間を Praat の TextGrid 形式で保存します。
+func writeTextGrid(filename string, result *julius.Result) error {
+	xmax := .0
+	phones := []interval{}
+	for _, seg := range result.Segments {
+		xmax = math.Max(xmax, seg.EndTime)
+		text := seg.Unit
+		if s, ok := julius.SpecialsForVSQX[text]; ok && s == "" {
+			text = ""
+		}
+		phones = append(phones, interval{begin: seg.BeginTime, end: seg.EndTime, text: text})
+	}
+	words := []interval{}
+	for _, p := range result.Phrases() {
+		words = append(words, interval{begin: p.BeginTime, end: p.EndTime, text: p.Text})
+	}
+	tiers := []struct {
+		name      string
+		intervals []interval
+	}{
+		{"phones", fillIntervals(phones, xmax)},
+		{"words", fillIntervals(words, xmax)},
+	}
+
+	var b bytes.Buffer
+	fmt.Fprint(&b, "File type = \"ooTextFile\"\nObject class = \"TextGrid\"\n\n")
+	fmt.Fprintf(&b, "xmin = 0\nxmax = %.7f\ntiers? <exists>\nsize = %d\nitem []:\n", xmax, len(tiers))
+	for i, tier := range tiers {
+		fmt.Fprintf(&b, "    item [%d]:\n", i+1)
+		fmt.Fprint(&b, "        class = \"IntervalTier\"\n")
+		fmt.Fprintf(&b, "        name = %s\n", textGridString(tier.name))
+		fmt.Fprintf(&b, "        xmin = 0\n        xmax = %.7f\n", xmax)
+		fmt.Fprintf(&b, "        intervals: size = %d\n", len(tier.intervals))
+		for j, v := range tier.intervals {
+			fmt.Fprintf(&b, "        intervals [%d]:\n", j+1)
+			fmt.Fprintf(&b, "            xmin = %.7f\n            xmax = %.7f\n", v.begin, v.end)
+			fmt.Fprintf(&b, "            text = %s\n", textGridString(v.text))
+		}
+	}
+	return ioutil.WriteFile(filename, b.Bytes(), 0644)
+}
+
+func srtTime(t float64) string {
+	ms := int(math.Round(math.Max(t, .0) * 1000.0))
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// writeSRT は、テキストファイルの行ごとの発音区間を SubRip 形式の字幕として保存します。
+func writeSRT(filename string, result *julius.Result) error {
+	var b bytes.Buffer
+	for i, p := range result.Phrases() {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, srtTime(p.BeginTime), srtTime(p.EndTime), p.Text)
+	}
+	return ioutil.WriteFile(filename, b.Bytes(), 0644)
+}
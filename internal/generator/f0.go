@@ -7,12 +7,11 @@ import (
 	"log"
 	"math"
 	"os"
-	"reflect"
 	"regexp"
 	"strconv"
 
 	"github.com/but80/talklistener/internal/world"
-	"github.com/mjibson/go-dsp/wav"
+	"github.com/mkb218/gosndfile/sndfile"
 	"golang.org/x/xerrors"
 )
 
@@ -22,55 +21,77 @@ const (
 	a3Note  = 69.0
 )
 
+// loadWav は、音声ファイルを読み込み、全チャンネルを平均したサンプル列とサンプリング周波数を返します。
+// 16bit整数のほか、24bit整数や32bit浮動小数点のファイルも精度を落とさずに読み込みます。
 func loadWav(filename string) ([]float64, int, error) {
-	file, err := os.Open(filename)
+	var info sndfile.Info
+	file, err := sndfile.Open(filename, sndfile.Read, &info)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer file.Close()
-	w, err := wav.New(file)
+
+	n := int(info.Frames)
+	ch := int(info.Channels)
+	samples := make([]float64, n*ch)
+	read, err := file.ReadFrames(samples)
 	if err != nil {
 		return nil, 0, err
 	}
-	samples, err := w.ReadSamples(w.Samples)
-	if err != nil {
-		return nil, 0, err
+	if int(read) != n {
+		return nil, 0, fmt.Errorf("Failed to read file (%d != %d): %s", read, n, filename)
 	}
-	result := make([]float64, w.Samples)
-	switch s := samples.(type) {
-	case []int16:
-		for i, v := range s {
-			result[i] = float64(v) / 32767.0
+	if ch == 1 {
+		return samples, int(info.Samplerate), nil
+	}
+	result := make([]float64, n)
+	for i := range result {
+		for j := 0; j < ch; j++ {
+			result[i] += samples[i*ch+j]
 		}
-	default:
-		return nil, 0, fmt.Errorf("Unsupported sample size: %s", reflect.TypeOf(samples))
+		result[i] /= float64(ch)
 	}
-	return result, int(w.SampleRate), nil
+	return result, int(info.Samplerate), nil
 }
 
-func wavToF0Note(infile, outfile string, framePeriod float64) ([]float64, error) {
+// wavToF0Note は、音声ファイルから基本周波数を推定し、ノート番号の系列に変換します。
+// 無声区間を補間したノート番号の系列を outfile に、推定した基本周波数（Hz, 無声区間は 0）を rawfile に保存します。
+func wavToF0Note(infile, outfile, rawfile string, framePeriod float64) ([]float64, []float64, error) {
 	log.Print("info: 基本周波数を推定中...")
 
 	x, fs, err := loadWav(infile)
 	if err != nil {
-		return nil, xerrors.Errorf("音声ファイルの読み込みに失敗しました: %w", err)
+		return nil, nil, xerrors.Errorf("音声ファイルの読み込みに失敗しました: %w", err)
+	}
+
+	f0 := world.Harvest(x, fs, framePeriod)
+	n0 := freqToNote(interpolate(f0))
+	if err := saveF0(outfile, n0, framePeriod); err != nil {
+		return nil, nil, err
+	}
+	if err := saveF0(rawfile, f0, framePeriod); err != nil {
+		return nil, nil, err
 	}
+	return n0, f0, nil
+}
 
-	file, err := os.OpenFile(outfile, os.O_CREATE|os.O_RDWR, 0644)
+func saveF0(filename string, values []float64, framePeriod float64) error {
+	file, err := os.Create(filename)
 	if err != nil {
-		return nil, xerrors.Errorf("基本周波数キャッシュファイルの作成に失敗しました: %w", err)
+		return xerrors.Errorf("基本周波数キャッシュファイルの作成に失敗しました: %w", err)
 	}
 	defer file.Close()
 
-	f0 := world.Harvest(x, fs, framePeriod)
-	n0 := freqToNote(interpolate(f0))
-	for i, n := range n0 {
-		_, err := fmt.Fprintf(file, "%.7f: %.2f\n", float64(i)*framePeriod, n)
-		if err != nil {
-			return nil, xerrors.Errorf("基本周波数キャッシュファイルの保存に失敗しました: %w", err)
+	w := bufio.NewWriter(file)
+	for i, v := range values {
+		if _, err := fmt.Fprintf(w, "%.7f: %.2f\n", float64(i)*framePeriod, v); err != nil {
+			return xerrors.Errorf("基本周波数キャッシュファイルの保存に失敗しました: %w", err)
 		}
 	}
-	return n0, nil
+	if err := w.Flush(); err != nil {
+		return xerrors.Errorf("基本周波数キャッシュファイルの保存に失敗しました: %w", err)
+	}
+	return nil
 }
 
 var loadF0NoteRx = regexp.MustCompile(`^\s*([\w\.\-]+)\W+([\w\.\-]+)`)
@@ -80,6 +101,7 @@ func loadF0Note(infile string) ([]float64, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("基本周波数キャッシュファイルの読み込みに失敗しました: %w", err)
 	}
+	defer file.Close()
 	result := []float64{}
 	r := bufio.NewReader(file)
 	for {
@@ -146,3 +168,11 @@ func resample(f0 []float64, n int) []float64 {
 	result[(len(f0)-1)*n] = f0[len(f0)-1]
 	return result
 }
+
+func noteToFreq(notes []float64) []float64 {
+	result := make([]float64, len(notes))
+	for i, n := range notes {
+		result[i] = a3Freq * math.Pow(2.0, (n-a3Note)/12.0)
+	}
+	return result
+}
@@ -0,0 +1,350 @@
+package generator
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+)
+
+const (
+	octaveAnchor      = .25  // オクターブエラーの補正の基準とする、有声区間の端の部分の最小の長さ（秒）
+	octaveTolerance   = 3.0  // オクターブの跳躍・ずれとみなす、1オクターブからの許容範囲（半音）
+	spikeWindow       = .025 // スパイクの判定の基準とする前後の区間長（秒）
+	spikeThreshold    = 4.0  // スパイクとみなす、前後の区間の中央値からのずれ（半音）
+	spikeMinVoiced    = .03  // これより短い有声区間はスパイクとして除去します（秒）
+	cubicMaxSlopeRate = 3.0  // 3次補間の端点の傾きの上限（区間の平均の傾きに対する比）
+)
+
+// InterpolateModes は、無声区間の基本周波数の補間方法として指定可能な値の一覧です。
+// "hold" は直前の値を保持し、"linear" は前後の値を直線で、"cubic" は単調な3次曲線で補間します。
+var InterpolateModes = []string{
+	"hold",
+	"linear",
+	"cubic",
+}
+
+// F0CleanupOptions は、推定した基本周波数に施す補正のパラメータです。
+// 各項目がゼロ値（Interpolate は "hold" も同様）のとき、その処理は行いません。
+type F0CleanupOptions struct {
+	OctaveFix   bool   // オクターブエラーを補正します
+	Despike     bool   // 短い有声区間や前後から大きく外れたフレームを無声とみなします
+	Median      int    // メディアンフィルタの幅（フレーム数）
+	Interpolate string // 無声区間の補間方法
+}
+
+func (c *F0CleanupOptions) isEmpty() bool {
+	return c == nil || !c.OctaveFix && !c.Despike && c.Median <= 1 && (c.Interpolate == "" || c.Interpolate == "hold")
+}
+
+func (c *F0CleanupOptions) validate() error {
+	if c.Interpolate != "" && !contains(InterpolateModes, c.Interpolate) {
+		return fmt.Errorf("無声区間の補間方法 %s は定義されていません", c.Interpolate)
+	}
+	if c.Median < 0 {
+		return fmt.Errorf("メディアンフィルタの幅は正の値で指定してください: %d", c.Median)
+	}
+	return nil
+}
+
+// cleanupF0 は、推定した基本周波数 f0（Hz, 無声区間は 0）を補正し、無声区間を補間したノート番号の系列と、
+// 補正後の基本周波数（Hz, 無声区間は 0）を返します。
+func cleanupF0(f0 []float64, c *F0CleanupOptions, framePeriod float64) ([]float64, []float64) {
+	notes := freqToNote(f0)
+	voiced := make([]bool, len(f0))
+	for i, f := range f0 {
+		voiced[i] = minFreq <= f
+	}
+	if c.OctaveFix {
+		n := fixOctaveErrors(notes, voiced, int(math.Round(octaveAnchor/framePeriod)))
+		log.Printf("info: オクターブエラーを補正したフレーム: %d", n)
+	}
+	if c.Despike {
+		n := removeSpikes(notes, voiced, int(math.Round(spikeWindow/framePeriod)), int(math.Round(spikeMinVoiced/framePeriod)))
+		log.Printf("info: スパイクとして除去したフレーム: %d", n)
+	}
+	if 1 < c.Median {
+		notes = medianFilter(notes, voiced, c.Median)
+	}
+
+	raw := noteToFreq(notes)
+	for i := range raw {
+		if !voiced[i] {
+			raw[i] = .0
+		}
+	}
+	switch c.Interpolate {
+	case "linear":
+		interpolateGaps(notes, voiced, linearGap)
+	case "cubic":
+		interpolateGaps(notes, voiced, cubicGap)
+	default:
+		interpolateGaps(notes, voiced, holdGap)
+	}
+	return notes, raw
+}
+
+// voicedMedian は、begin〜end-1 フレームのうち有声のフレームの中央値を返します。
+func voicedMedian(notes []float64, voiced []bool, begin, end int) (float64, bool) {
+	if begin < 0 {
+		begin = 0
+	}
+	if len(notes) < end {
+		end = len(notes)
+	}
+	values := []float64{}
+	for i := begin; i < end; i++ {
+		if voiced[i] {
+			values = append(values, notes[i])
+		}
+	}
+	if len(values) == 0 {
+		return .0, false
+	}
+	sort.Float64s(values)
+	return percentile(values, .5), true
+}
+
+// fixOctaveErrors は、基本周波数のオクターブエラーを補正し、補正したフレーム数を返します。
+//
+// まず有声区間ごとに、隣接フレーム間でおよそ1オクターブ跳んだ位置で区切り、区切った各部分の相対的なオクターブを求めます。
+// 有声区間の端にある w フレーム以上の部分を基準とし（両端が該当して食い違う場合は長い方、いずれも該当しない場合は
+// 最もフレーム数の多いオクターブ）、他の部分を基準のオクターブに揃えます。
+// 次に、有声区間全体が発話全体の中央値からおよそ1オクターブ以上ずれている場合、その有声区間をオクターブ単位で中央値に近づけます。
+func fixOctaveErrors(notes []float64, voiced []bool, w int) int {
+	count := 0
+	runs := [][2]int{}
+	for i := 0; i < len(voiced); {
+		if !voiced[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(voiced) && voiced[j] {
+			j++
+		}
+		runs = append(runs, [2]int{i, j})
+		count += fixRunOctave(notes[i:j], w)
+		i = j
+	}
+
+	global, ok := voicedMedian(notes, voiced, 0, len(notes))
+	if !ok {
+		return count
+	}
+	for _, r := range runs {
+		m, _ := voicedMedian(notes, voiced, r[0], r[1])
+		d := m - global
+		if math.Abs(d) < 12.0-octaveTolerance {
+			continue
+		}
+		shift := 12.0 * math.Round(d/12.0)
+		for i := r[0]; i < r[1]; i++ {
+			notes[i] -= shift
+		}
+		count += r[1] - r[0]
+	}
+	return count
+}
+
+// fixRunOctave は、1つの有声区間 notes のオクターブを揃え、補正したフレーム数を返します。
+func fixRunOctave(notes []float64, w int) int {
+	type piece struct{ begin, end, octave int }
+	pieces := []piece{{begin: 0, octave: 0}}
+	for i := 1; i < len(notes); i++ {
+		d := notes[i] - notes[i-1]
+		if math.Abs(d) < 12.0-octaveTolerance {
+			continue
+		}
+		last := &pieces[len(pieces)-1]
+		last.end = i
+		pieces = append(pieces, piece{begin: i, octave: last.octave + int(math.Round(d/12.0))})
+	}
+	pieces[len(pieces)-1].end = len(notes)
+	if len(pieces) == 1 {
+		return 0
+	}
+
+	first, last := pieces[0], pieces[len(pieces)-1]
+	var base int
+	switch {
+	case w <= first.end-first.begin && w <= last.end-last.begin:
+		base = first.octave
+		if first.octave != last.octave && first.end-first.begin < last.end-last.begin {
+			base = last.octave
+		}
+	case w <= first.end-first.begin:
+		base = first.octave
+	case w <= last.end-last.begin:
+		base = last.octave
+	default:
+		frames := map[int]int{}
+		for _, p := range pieces {
+			frames[p.octave] += p.end - p.begin
+		}
+		base = first.octave
+		for octave, n := range frames {
+			if frames[base] < n || frames[base] == n && octave < base {
+				base = octave
+			}
+		}
+	}
+
+	count := 0
+	for _, p := range pieces {
+		if p.octave == base {
+			continue
+		}
+		for i := p.begin; i < p.end; i++ {
+			notes[i] -= 12.0 * float64(p.octave-base)
+		}
+		count += p.end - p.begin
+	}
+	return count
+}
+
+// removeSpikes は、minVoiced フレームより短い有声区間と、前後 w フレームの中央値から
+// spikeThreshold 半音以上外れたフレームを無声とみなします。無声とみなしたフレーム数を返します。
+func removeSpikes(notes []float64, voiced []bool, w, minVoiced int) int {
+	count := 0
+	spikes := []int{}
+	for i := range notes {
+		if !voiced[i] {
+			continue
+		}
+		if m, ok := voicedMedian(notes, voiced, i-w, i+w+1); ok && spikeThreshold <= math.Abs(notes[i]-m) {
+			spikes = append(spikes, i)
+		}
+	}
+	for _, i := range spikes {
+		voiced[i] = false
+		count++
+	}
+	for i := 0; i < len(voiced); {
+		if !voiced[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(voiced) && voiced[j] {
+			j++
+		}
+		if j-i < minVoiced {
+			for k := i; k < j; k++ {
+				voiced[k] = false
+				count++
+			}
+		}
+		i = j
+	}
+	return count
+}
+
+// medianFilter は、有声区間ごとに幅 w フレームのメディアンフィルタをかけます。
+// 窓は有声区間をはみ出さないように切り詰めます。
+func medianFilter(notes []float64, voiced []bool, w int) []float64 {
+	result := make([]float64, len(notes))
+	copy(result, notes)
+	for i := 0; i < len(voiced); {
+		if !voiced[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(voiced) && voiced[j] {
+			j++
+		}
+		for k := i; k < j; k++ {
+			b := k - w/2
+			if b < i {
+				b = i
+			}
+			e := k - w/2 + w
+			if j < e {
+				e = j
+			}
+			result[k], _ = voicedMedian(notes, voiced, b, e)
+		}
+		i = j
+	}
+	return result
+}
+
+// gapFunc は、値 a のフレームと値 b のフレームの間の無声区間を補間します。
+// ma, mb は端点の傾き（1フレームあたり）、gap は補間するフレームの列です。
+type gapFunc func(a, b, ma, mb float64, gap []float64)
+
+func holdGap(a, b, ma, mb float64, gap []float64) {
+	for i := range gap {
+		gap[i] = a
+	}
+}
+
+func linearGap(a, b, ma, mb float64, gap []float64) {
+	n := float64(len(gap) + 1)
+	for i := range gap {
+		gap[i] = a + (b-a)*float64(i+1)/n
+	}
+}
+
+// cubicGap は、3次エルミート曲線で補間します。
+// 端点の傾きは、行き過ぎが生じないよう区間の平均の傾きと符号を揃え、大きさを制限します。
+func cubicGap(a, b, ma, mb float64, gap []float64) {
+	n := float64(len(gap) + 1)
+	secant := (b - a) / n
+	limit := func(m float64) float64 {
+		if m*secant <= 0 {
+			return .0
+		}
+		if max := cubicMaxSlopeRate * math.Abs(secant); max < math.Abs(m) {
+			return math.Copysign(max, m)
+		}
+		return m
+	}
+	ma = limit(ma) * n
+	mb = limit(mb) * n
+	for i := range gap {
+		t := float64(i+1) / n
+		t2 := t * t
+		t3 := t2 * t
+		gap[i] = (2*t3-3*t2+1)*a + (t3-2*t2+t)*ma + (-2*t3+3*t2)*b + (t3-t2)*mb
+	}
+}
+
+// interpolateGaps は、無声のフレームを前後の有声のフレームから補間します。
+// 先頭と末尾の無声区間は、最も近い有声のフレームの値を保持します（有声のフレームがない場合は a3Note）。
+func interpolateGaps(notes []float64, voiced []bool, f gapFunc) {
+	last := -1
+	for i := 0; i <= len(notes); i++ {
+		if i < len(notes) && !voiced[i] {
+			continue
+		}
+		if last+1 < i {
+			gap := notes[last+1 : i]
+			switch {
+			case last < 0 && i == len(notes):
+				for k := range gap {
+					gap[k] = a3Note
+				}
+			case last < 0:
+				for k := range gap {
+					gap[k] = notes[i]
+				}
+			case i == len(notes):
+				for k := range gap {
+					gap[k] = notes[last]
+				}
+			default:
+				ma, mb := .0, .0
+				if 0 < last && voiced[last-1] {
+					ma = notes[last] - notes[last-1]
+				}
+				if i+1 < len(notes) && voiced[i+1] {
+					mb = notes[i+1] - notes[i]
+				}
+				f(notes[last], notes[i], ma, mb, gap)
+			}
+		}
+		last = i
+	}
+}
@@ -0,0 +1,158 @@
+package generator
+
+import (
+	"math"
+	"testing"
+)
+
+// f0Segment は、テスト用の基本周波数の系列の一部です（note が 0 の場合は無声）。
+type f0Segment struct {
+	frames int
+	note   float64
+}
+
+func makeNotes(segments []f0Segment) ([]float64, []bool) {
+	notes := []float64{}
+	voiced := []bool{}
+	for _, s := range segments {
+		for i := 0; i < s.frames; i++ {
+			notes = append(notes, s.note)
+			voiced = append(voiced, s.note != 0)
+		}
+	}
+	return notes, voiced
+}
+
+func TestFixOctaveErrors(t *testing.T) {
+	const w = 50 // octaveAnchor / f0FramePeriod
+	tests := []struct {
+		name  string
+		input []f0Segment
+		want  []f0Segment
+		count int
+	}{
+		{
+			name:  "no error",
+			input: []f0Segment{{100, 55}, {100, 62}, {100, 58}},
+			want:  []f0Segment{{100, 55}, {100, 62}, {100, 58}},
+		},
+		{
+			name:  "stretch an octave up",
+			input: []f0Segment{{150, 55}, {60, 67}, {190, 55}},
+			want:  []f0Segment{{150, 55}, {60, 55}, {190, 55}},
+			count: 60,
+		},
+		{
+			name:  "long stretch with short tail",
+			input: []f0Segment{{60, 55}, {120, 67}, {20, 55}},
+			want:  []f0Segment{{60, 55}, {120, 55}, {20, 55}},
+			count: 120,
+		},
+		{
+			name:  "short head",
+			input: []f0Segment{{10, 67}, {190, 55}},
+			want:  []f0Segment{{10, 55}, {190, 55}},
+			count: 10,
+		},
+		{
+			name:  "octave down",
+			input: []f0Segment{{100, 55}, {30, 43}, {100, 55}},
+			want:  []f0Segment{{100, 55}, {30, 55}, {100, 55}},
+			count: 30,
+		},
+		{
+			name:  "short edges",
+			input: []f0Segment{{10, 67}, {100, 55}, {10, 67}},
+			want:  []f0Segment{{10, 55}, {100, 55}, {10, 55}},
+			count: 20,
+		},
+		{
+			name:  "whole run",
+			input: []f0Segment{{100, 55}, {20, 0}, {30, 67}, {20, 0}, {100, 56}},
+			want:  []f0Segment{{100, 55}, {20, 0}, {30, 55}, {20, 0}, {100, 56}},
+			count: 30,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes, voiced := makeNotes(tt.input)
+			want, _ := makeNotes(tt.want)
+			if n := fixOctaveErrors(notes, voiced, w); n != tt.count {
+				t.Errorf("fixOctaveErrors() = %d, want %d", n, tt.count)
+			}
+			for i := range notes {
+				if notes[i] != want[i] {
+					t.Errorf("notes[%d] = %g, want %g", i, notes[i], want[i])
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveSpikes(t *testing.T) {
+	notes, voiced := makeNotes([]f0Segment{{50, 55}, {1, 62}, {50, 55}, {10, 0}, {3, 60}, {10, 0}, {50, 57}})
+	if n := removeSpikes(notes, voiced, 5, 6); n != 4 {
+		t.Errorf("removeSpikes() = %d, want 4", n)
+	}
+	for i, v := range voiced {
+		want := i != 50 && (i <= 100 || 124 <= i)
+		if v != want {
+			t.Errorf("voiced[%d] = %v, want %v", i, v, want)
+		}
+	}
+}
+
+func TestMedianFilter(t *testing.T) {
+	notes, voiced := makeNotes([]f0Segment{{5, 55}, {1, 58}, {5, 55}, {3, 0}, {5, 60}})
+	got := medianFilter(notes, voiced, 5)
+	want, _ := makeNotes([]f0Segment{{11, 55}, {3, 0}, {5, 60}})
+	for i := range got {
+		if voiced[i] && got[i] != want[i] {
+			t.Errorf("medianFilter()[%d] = %g, want %g", i, got[i], want[i])
+		}
+	}
+}
+
+func TestInterpolateGaps(t *testing.T) {
+	tests := []struct {
+		name string
+		f    gapFunc
+		want []float64
+	}{
+		{name: "hold", f: holdGap, want: []float64{60, 60, 60, 60, 60, 60, 64, 64, 64, 64}},
+		{name: "linear", f: linearGap, want: []float64{60, 60, 60, 61, 62, 63, 64, 64, 64, 64}},
+	}
+	input := func() ([]float64, []bool) {
+		return makeNotes([]f0Segment{{1, 0}, {2, 60}, {3, 0}, {2, 64}, {2, 0}})
+	}
+	for _, tt := range tests {
+		notes, voiced := input()
+		interpolateGaps(notes, voiced, tt.f)
+		for i := range notes {
+			if math.Abs(notes[i]-tt.want[i]) > 1e-9 {
+				t.Errorf("%s: notes[%d] = %g, want %g", tt.name, i, notes[i], tt.want[i])
+			}
+		}
+	}
+
+	// 3次補間は、端点を結ぶ単調な曲線になること
+	notes, voiced := input()
+	interpolateGaps(notes, voiced, cubicGap)
+	for i := 3; i <= 6; i++ {
+		if !(notes[i-1] < notes[i] && notes[i] <= 64) {
+			t.Errorf("cubic: notes[%d] = %g is not monotonic (notes[%d] = %g)", i, notes[i], i-1, notes[i-1])
+		}
+	}
+	if notes[0] != 60 || notes[9] != 64 {
+		t.Errorf("cubic: edges = %g, %g, want 60, 64", notes[0], notes[9])
+	}
+
+	notes, voiced = makeNotes([]f0Segment{{5, 0}})
+	interpolateGaps(notes, voiced, linearGap)
+	for i := range notes {
+		if notes[i] != a3Note {
+			t.Errorf("unvoiced: notes[%d] = %g, want %g", i, notes[i], a3Note)
+		}
+	}
+}
@@ -1,6 +1,33 @@
 package generator
 
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/mjibson/go-dsp/fft"
+)
+
+const (
+	fftConvolveMinLength = 1 << 14 // これ以上の長さの系列には FFT による畳み込みを用いる
+	fftConvolveMinTaps   = 32
+)
+
+// convolve は、wave の先頭の値が過去にも続いていたものとみなして、FIRフィルタ ir をかけます。
+// 長い系列に対しては、結果が同等となる FFT による重畳加算法を用います。
 func convolve(wave, ir []float64) []float64 {
+	if useFFTConvolve(len(wave), len(ir)) {
+		return convolveFFT(wave, ir)
+	}
+	return convolveDirect(wave, ir)
+}
+
+// useFFTConvolve は、長さ n の系列にタップ数 taps のフィルタをかける際に FFT を用いるかどうかを返します。
+func useFFTConvolve(n, taps int) bool {
+	return fftConvolveMinLength <= n && fftConvolveMinTaps <= taps
+}
+
+func convolveDirect(wave, ir []float64) []float64 {
 	result := make([]float64, len(wave))
 	if len(wave) == 0 {
 		return result
@@ -17,159 +44,161 @@ func convolve(wave, ir []float64) []float64 {
 	return result
 }
 
-var FIRLPFCutoffs = []string{
-	"0.5", "1.0", "1.5", "2.0", "2.5", "3.0",
+func convolveFFT(wave, ir []float64) []float64 {
+	result := make([]float64, len(wave))
+	if len(wave) == 0 {
+		return result
+	}
+	base := wave[0]
+
+	size := 1
+	for size < len(ir)*8 {
+		size *= 2
+	}
+	block := size - len(ir) + 1
+	buf := make([]float64, size)
+	copy(buf, ir)
+	irSpec := fft.FFTReal(buf)
+
+	for begin := 0; begin < len(wave); begin += block {
+		for i := range buf {
+			buf[i] = .0
+			if i < block && begin+i < len(wave) {
+				buf[i] = wave[begin+i] - base
+			}
+		}
+		spec := fft.FFTReal(buf)
+		for i := range spec {
+			spec[i] *= irSpec[i]
+		}
+		for i, v := range fft.IFFT(spec) {
+			if len(result) <= begin+i {
+				break
+			}
+			result[begin+i] += real(v)
+		}
+	}
+	for i := range result {
+		result[i] += base
+	}
+	return result
+}
+
+// filtfilt は、FIRフィルタを順方向・逆方向に1回ずつかけることで、位相遅れのないフィルタリングを行います。
+// 両端は信号を点対称に折り返して延長し、端部の過渡応答を抑えます。
+func filtfilt(wave, ir []float64) []float64 {
+	n := len(wave)
+	if n < 2 {
+		return append([]float64{}, wave...)
+	}
+	pad := len(ir)
+	if n-1 < pad {
+		pad = n - 1
+	}
+	ext := make([]float64, n+pad*2)
+	for i := 0; i < pad; i++ {
+		ext[i] = 2.0*wave[0] - wave[pad-i]
+		ext[pad+n+i] = 2.0*wave[n-1] - wave[n-2-i]
+	}
+	copy(ext[pad:], wave)
+
+	ext = reverse(convolve(ext, ir))
+	ext = reverse(convolve(ext, ir))
+	return ext[pad : pad+n]
+}
+
+func reverse(wave []float64) []float64 {
+	for i, j := 0, len(wave)-1; i < j; i, j = i+1, j-1 {
+		wave[i], wave[j] = wave[j], wave[i]
+	}
+	return wave
+}
+
+// legacyLPFCutoffs は、以前のバージョンで指定可能だったカットオフ周波数です。
+// 以前は係数表を 5 倍のサンプリング周波数の系列に適用していたため、実際のカットオフ周波数は指定値の legacyLPFScale 倍でした。
+var legacyLPFCutoffs = []float64{.5, 1.0, 1.5, 2.0, 2.5, 3.0}
+
+const legacyLPFScale = resampleRate
+
+// warnLegacyLPFCutoff は、カットオフ周波数が以前のバージョンの指定値と一致する場合に警告します。
+func warnLegacyLPFCutoff(cutoff float64) {
+	for _, c := range legacyLPFCutoffs {
+		if cutoff == c {
+			log.Printf("warn: --f0-cutoff %g は以前のバージョンでは約 %g Hz に相当していました。以前と同じ特性にするには %g を指定してください", cutoff, cutoff*legacyLPFScale, cutoff*legacyLPFScale)
+			return
+		}
+	}
+}
+
+// F0FilterModes は、基本周波数の変動にかけるフィルタの方式として指定可能な値の一覧です。
+// "zerophase" は順方向・逆方向の2回フィルタリングし、"causal" は従来どおり1回だけかけて遅延を補償します。
+var F0FilterModes = []string{
+	"zerophase",
+	"causal",
+}
+
+// FIRWindows は、LPFの設計に使用可能な窓関数の一覧です。
+var FIRWindows = []string{
+	"hamming",
+	"hann",
+	"blackman",
+	"rectangular",
+}
+
+func window(name string, n int) ([]float64, error) {
+	result := make([]float64, n)
+	for i := range result {
+		if n == 1 {
+			result[i] = 1.0
+			continue
+		}
+		x := 2.0 * math.Pi * float64(i) / float64(n-1)
+		switch name {
+		case "hamming":
+			result[i] = .54 - .46*math.Cos(x)
+		case "hann":
+			result[i] = .5 - .5*math.Cos(x)
+		case "blackman":
+			result[i] = .42 - .5*math.Cos(x) + .08*math.Cos(2.0*x)
+		case "rectangular":
+			result[i] = 1.0
+		default:
+			return nil, fmt.Errorf("窓関数 %s は定義されていません", name)
+		}
+	}
+	return result, nil
+}
+
+// designLPF は、窓関数法によりFIRローパスフィルタを設計します。
+// cutoff はサンプリング周波数 fs に対するカットオフ周波数で、直流での利得が1になるよう正規化します。
+func designLPF(cutoff, fs float64, taps int, windowName string) ([]float64, error) {
+	if taps < 1 {
+		return nil, fmt.Errorf("タップ数 %d が不正です", taps)
+	}
+	if cutoff <= .0 || fs/2.0 <= cutoff {
+		return nil, fmt.Errorf("カットオフ周波数 %g が不正です", cutoff)
+	}
+	w, err := window(windowName, taps)
+	if err != nil {
+		return nil, err
+	}
+	fc := cutoff / (fs / 2.0)
+	result := make([]float64, taps)
+	sum := .0
+	for i := range result {
+		m := float64(i) - float64(taps-1)/2.0
+		result[i] = fc * sinc(fc*m) * w[i]
+		sum += result[i]
+	}
+	for i := range result {
+		result[i] /= sum
+	}
+	return result, nil
 }
 
-var firLPF = map[string][]float64{
-	"0.5": {
-		+4.161265e-04, +4.219242e-04, +4.297244e-04, +4.395910e-04, +4.515859e-04, +4.657682e-04, +4.821949e-04, +5.009201e-04, +5.219955e-04, +5.454696e-04,
-		+5.713884e-04, +5.997947e-04, +6.307280e-04, +6.642250e-04, +7.003188e-04, +7.390394e-04, +7.804131e-04, +8.244629e-04, +8.712082e-04, +9.206647e-04,
-		+9.728444e-04, +1.027756e-03, +1.085403e-03, +1.145787e-03, +1.208905e-03, +1.274749e-03, +1.343309e-03, +1.414569e-03, +1.488512e-03, +1.565113e-03,
-		+1.644347e-03, +1.726182e-03, +1.810585e-03, +1.897516e-03, +1.986933e-03, +2.078790e-03, +2.173036e-03, +2.269618e-03, +2.368478e-03, +2.469555e-03,
-		+2.572784e-03, +2.678096e-03, +2.785419e-03, +2.894677e-03, +3.005793e-03, +3.118683e-03, +3.233262e-03, +3.349442e-03, +3.467132e-03, +3.586238e-03,
-		+3.706662e-03, +3.828305e-03, +3.951065e-03, +4.074836e-03, +4.199513e-03, +4.324987e-03, +4.451145e-03, +4.577876e-03, +4.705064e-03, +4.832594e-03,
-		+4.960348e-03, +5.088206e-03, +5.216048e-03, +5.343754e-03, +5.471201e-03, +5.598267e-03, +5.724828e-03, +5.850760e-03, +5.975941e-03, +6.100246e-03,
-		+6.223551e-03, +6.345733e-03, +6.466670e-03, +6.586237e-03, +6.704315e-03, +6.820783e-03, +6.935520e-03, +7.048408e-03, +7.159331e-03, +7.268174e-03,
-		+7.374822e-03, +7.479163e-03, +7.581090e-03, +7.680493e-03, +7.777267e-03, +7.871311e-03, +7.962523e-03, +8.050807e-03, +8.136068e-03, +8.218214e-03,
-		+8.297158e-03, +8.372813e-03, +8.445100e-03, +8.513938e-03, +8.579255e-03, +8.640978e-03, +8.699041e-03, +8.753380e-03, +8.803937e-03, +8.850656e-03,
-		+8.893485e-03, +8.932378e-03, +8.967293e-03, +8.998192e-03, +9.025039e-03, +9.047806e-03, +9.066468e-03, +9.081004e-03, +9.091398e-03, +9.097640e-03,
-		+9.099721e-03, +9.097640e-03, +9.091398e-03, +9.081004e-03, +9.066468e-03, +9.047806e-03, +9.025039e-03, +8.998192e-03, +8.967293e-03, +8.932378e-03,
-		+8.893485e-03, +8.850656e-03, +8.803937e-03, +8.753380e-03, +8.699041e-03, +8.640978e-03, +8.579255e-03, +8.513938e-03, +8.445100e-03, +8.372813e-03,
-		+8.297158e-03, +8.218214e-03, +8.136068e-03, +8.050807e-03, +7.962523e-03, +7.871311e-03, +7.777267e-03, +7.680493e-03, +7.581090e-03, +7.479163e-03,
-		+7.374822e-03, +7.268174e-03, +7.159331e-03, +7.048408e-03, +6.935520e-03, +6.820783e-03, +6.704315e-03, +6.586237e-03, +6.466670e-03, +6.345733e-03,
-		+6.223551e-03, +6.100246e-03, +5.975941e-03, +5.850760e-03, +5.724828e-03, +5.598267e-03, +5.471201e-03, +5.343754e-03, +5.216048e-03, +5.088206e-03,
-		+4.960348e-03, +4.832594e-03, +4.705064e-03, +4.577876e-03, +4.451145e-03, +4.324987e-03, +4.199513e-03, +4.074836e-03, +3.951065e-03, +3.828305e-03,
-		+3.706662e-03, +3.586238e-03, +3.467132e-03, +3.349442e-03, +3.233262e-03, +3.118683e-03, +3.005793e-03, +2.894677e-03, +2.785419e-03, +2.678096e-03,
-		+2.572784e-03, +2.469555e-03, +2.368478e-03, +2.269618e-03, +2.173036e-03, +2.078790e-03, +1.986933e-03, +1.897516e-03, +1.810585e-03, +1.726182e-03,
-		+1.644347e-03, +1.565113e-03, +1.488512e-03, +1.414569e-03, +1.343309e-03, +1.274749e-03, +1.208905e-03, +1.145787e-03, +1.085403e-03, +1.027756e-03,
-		+9.728444e-04, +9.206647e-04, +8.712082e-04, +8.244629e-04, +7.804131e-04, +7.390394e-04, +7.003188e-04, +6.642250e-04, +6.307280e-04, +5.997947e-04,
-		+5.713884e-04, +5.454696e-04, +5.219955e-04, +5.009201e-04, +4.821949e-04, +4.657682e-04, +4.515859e-04, +4.395910e-04, +4.297244e-04, +4.219242e-04,
-		+4.161265e-04,
-	},
-	"1.0": {
-		-8.073641e-05, -7.373281e-05, -6.679867e-05, -5.982777e-05, -5.270841e-05, -4.532363e-05, -3.755158e-05, -2.926580e-05, -2.033559e-05, -1.062635e-05,
-		+4.339342e-20, +1.168466e-05, +2.457153e-05, +3.880674e-05, +5.453828e-05, +7.191549e-05, +9.108862e-05, +1.122083e-04, +1.354253e-04, +1.608896e-04,
-		+1.887502e-04, +2.191547e-04, +2.522487e-04, +2.881750e-04, +3.270736e-04, +3.690809e-04, +4.143292e-04, +4.629466e-04, +5.150557e-04, +5.707741e-04,
-		+6.302131e-04, +6.934778e-04, +7.606665e-04, +8.318701e-04, +9.071718e-04, +9.866470e-04, +1.070362e-03, +1.158376e-03, +1.250736e-03, +1.347482e-03,
-		+1.448644e-03, +1.554241e-03, +1.664283e-03, +1.778767e-03, +1.897683e-03, +2.021007e-03, +2.148705e-03, +2.280733e-03, +2.417034e-03, +2.557540e-03,
-		+2.702174e-03, +2.850844e-03, +3.003449e-03, +3.159878e-03, +3.320007e-03, +3.483700e-03, +3.650813e-03, +3.821191e-03, +3.994666e-03, +4.171063e-03,
-		+4.350195e-03, +4.531866e-03, +4.715872e-03, +4.901999e-03, +5.090024e-03, +5.279717e-03, +5.470841e-03, +5.663150e-03, +5.856393e-03, +6.050311e-03,
-		+6.244641e-03, +6.439115e-03, +6.633459e-03, +6.827397e-03, +7.020647e-03, +7.212928e-03, +7.403952e-03, +7.593434e-03, +7.781085e-03, +7.966618e-03,
-		+8.149744e-03, +8.330178e-03, +8.507634e-03, +8.681829e-03, +8.852485e-03, +9.019324e-03, +9.182075e-03, +9.340471e-03, +9.494251e-03, +9.643159e-03,
-		+9.786947e-03, +9.925373e-03, +1.005820e-02, +1.018521e-02, +1.030619e-02, +1.042092e-02, +1.052921e-02, +1.063087e-02, +1.072573e-02, +1.081363e-02,
-		+1.089440e-02, +1.096792e-02, +1.103405e-02, +1.109267e-02, +1.114369e-02, +1.118701e-02, +1.122256e-02, +1.125027e-02, +1.127010e-02, +1.128202e-02,
-		+1.128599e-02, +1.128202e-02, +1.127010e-02, +1.125027e-02, +1.122256e-02, +1.118701e-02, +1.114369e-02, +1.109267e-02, +1.103405e-02, +1.096792e-02,
-		+1.089440e-02, +1.081363e-02, +1.072573e-02, +1.063087e-02, +1.052921e-02, +1.042092e-02, +1.030619e-02, +1.018521e-02, +1.005820e-02, +9.925373e-03,
-		+9.786947e-03, +9.643159e-03, +9.494251e-03, +9.340471e-03, +9.182075e-03, +9.019324e-03, +8.852485e-03, +8.681829e-03, +8.507634e-03, +8.330178e-03,
-		+8.149744e-03, +7.966618e-03, +7.781085e-03, +7.593434e-03, +7.403952e-03, +7.212928e-03, +7.020647e-03, +6.827397e-03, +6.633459e-03, +6.439115e-03,
-		+6.244641e-03, +6.050311e-03, +5.856393e-03, +5.663150e-03, +5.470841e-03, +5.279717e-03, +5.090024e-03, +4.901999e-03, +4.715872e-03, +4.531866e-03,
-		+4.350195e-03, +4.171063e-03, +3.994666e-03, +3.821191e-03, +3.650813e-03, +3.483700e-03, +3.320007e-03, +3.159878e-03, +3.003449e-03, +2.850844e-03,
-		+2.702174e-03, +2.557540e-03, +2.417034e-03, +2.280733e-03, +2.148705e-03, +2.021007e-03, +1.897683e-03, +1.778767e-03, +1.664283e-03, +1.554241e-03,
-		+1.448644e-03, +1.347482e-03, +1.250736e-03, +1.158376e-03, +1.070362e-03, +9.866470e-04, +9.071718e-04, +8.318701e-04, +7.606665e-04, +6.934778e-04,
-		+6.302131e-04, +5.707741e-04, +5.150557e-04, +4.629466e-04, +4.143292e-04, +3.690809e-04, +3.270736e-04, +2.881750e-04, +2.522487e-04, +2.191547e-04,
-		+1.887502e-04, +1.608896e-04, +1.354253e-04, +1.122083e-04, +9.108862e-05, +7.191549e-05, +5.453828e-05, +3.880674e-05, +2.457153e-05, +1.168466e-05,
-		+4.339342e-20, -1.062635e-05, -2.033559e-05, -2.926580e-05, -3.755158e-05, -4.532363e-05, -5.270841e-05, -5.982777e-05, -6.679867e-05, -7.373281e-05,
-		-8.073641e-05,
-	},
-	"1.5": {
-		-2.073108e-04, -2.145038e-04, -2.224038e-04, -2.310709e-04, -2.405535e-04, -2.508867e-04, -2.620924e-04, -2.741776e-04, -2.871342e-04, -3.009382e-04,
-		-3.155491e-04, -3.309095e-04, -3.469447e-04, -3.635621e-04, -3.806516e-04, -3.980846e-04, -4.157151e-04, -4.333787e-04, -4.508936e-04, -4.680605e-04,
-		-4.846629e-04, -5.004682e-04, -5.152276e-04, -5.286773e-04, -5.405391e-04, -5.505214e-04, -5.583201e-04, -5.636201e-04, -5.660959e-04, -5.654133e-04,
-		-5.612306e-04, -5.532001e-04, -5.409697e-04, -5.241840e-04, -5.024868e-04, -4.755217e-04, -4.429346e-04, -4.043753e-04, -3.594988e-04, -3.079678e-04,
-		-2.494539e-04, -1.836398e-04, -1.102209e-04, -2.890694e-05, +6.057590e-05, +1.584835e-04, +2.650522e-04, +3.804969e-04, +5.050098e-04, +6.387587e-04,
-		+7.818855e-04, +9.345053e-04, +1.096704e-03, +1.268540e-03, +1.450038e-03, +1.641194e-03, +1.841969e-03, +2.052294e-03, +2.272064e-03, +2.501141e-03,
-		+2.739350e-03, +2.986486e-03, +3.242305e-03, +3.506530e-03, +3.778850e-03, +4.058919e-03, +4.346359e-03, +4.640757e-03, +4.941670e-03, +5.248622e-03,
-		+5.561109e-03, +5.878595e-03, +6.200521e-03, +6.526298e-03, +6.855314e-03, +7.186935e-03, +7.520505e-03, +7.855349e-03, +8.190776e-03, +8.526079e-03,
-		+8.860539e-03, +9.193426e-03, +9.524002e-03, +9.851524e-03, +1.017524e-02, +1.049441e-02, +1.080829e-02, +1.111613e-02, +1.141719e-02, +1.171075e-02,
-		+1.199610e-02, +1.227253e-02, +1.253936e-02, +1.279593e-02, +1.304158e-02, +1.327570e-02, +1.349770e-02, +1.370700e-02, +1.390306e-02, +1.408539e-02,
-		+1.425351e-02, +1.440697e-02, +1.454539e-02, +1.466839e-02, +1.477566e-02, +1.486691e-02, +1.494190e-02, +1.500043e-02, +1.504235e-02, +1.506755e-02,
-		+1.507595e-02, +1.506755e-02, +1.504235e-02, +1.500043e-02, +1.494190e-02, +1.486691e-02, +1.477566e-02, +1.466839e-02, +1.454539e-02, +1.440697e-02,
-		+1.425351e-02, +1.408539e-02, +1.390306e-02, +1.370700e-02, +1.349770e-02, +1.327570e-02, +1.304158e-02, +1.279593e-02, +1.253936e-02, +1.227253e-02,
-		+1.199610e-02, +1.171075e-02, +1.141719e-02, +1.111613e-02, +1.080829e-02, +1.049441e-02, +1.017524e-02, +9.851524e-03, +9.524002e-03, +9.193426e-03,
-		+8.860539e-03, +8.526079e-03, +8.190776e-03, +7.855349e-03, +7.520505e-03, +7.186935e-03, +6.855314e-03, +6.526298e-03, +6.200521e-03, +5.878595e-03,
-		+5.561109e-03, +5.248622e-03, +4.941670e-03, +4.640757e-03, +4.346359e-03, +4.058919e-03, +3.778850e-03, +3.506530e-03, +3.242305e-03, +2.986486e-03,
-		+2.739350e-03, +2.501141e-03, +2.272064e-03, +2.052294e-03, +1.841969e-03, +1.641194e-03, +1.450038e-03, +1.268540e-03, +1.096704e-03, +9.345053e-04,
-		+7.818855e-04, +6.387587e-04, +5.050098e-04, +3.804969e-04, +2.650522e-04, +1.584835e-04, +6.057590e-05, -2.890694e-05, -1.102209e-04, -1.836398e-04,
-		-2.494539e-04, -3.079678e-04, -3.594988e-04, -4.043753e-04, -4.429346e-04, -4.755217e-04, -5.024868e-04, -5.241840e-04, -5.409697e-04, -5.532001e-04,
-		-5.612306e-04, -5.654133e-04, -5.660959e-04, -5.636201e-04, -5.583201e-04, -5.505214e-04, -5.405391e-04, -5.286773e-04, -5.152276e-04, -5.004682e-04,
-		-4.846629e-04, -4.680605e-04, -4.508936e-04, -4.333787e-04, -4.157151e-04, -3.980846e-04, -3.806516e-04, -3.635621e-04, -3.469447e-04, -3.309095e-04,
-		-3.155491e-04, -3.009382e-04, -2.871342e-04, -2.741776e-04, -2.620924e-04, -2.508867e-04, -2.405535e-04, -2.310709e-04, -2.224038e-04, -2.145038e-04,
-		-2.073108e-04,
-	},
-	"2.0": {
-		+1.356453e-04, +1.250818e-04, +1.142967e-04, +1.031442e-04, +9.146344e-05, +7.908130e-05, +6.581415e-05, +5.147044e-05, +3.585321e-05, +1.876286e-05,
-		-7.665721e-20, -2.063151e-05, -4.332149e-05, -6.825031e-05, -9.558560e-05, -1.254791e-04, -1.580636e-04, -1.934493e-04, -2.317212e-04, -2.729361e-04,
-		-3.171194e-04, -3.642626e-04, -4.143206e-04, -4.672098e-04, -5.228055e-04, -5.809403e-04, -6.414028e-04, -7.039357e-04, -7.682357e-04, -8.339523e-04,
-		-9.006876e-04, -9.679970e-04, -1.035389e-03, -1.102326e-03, -1.168228e-03, -1.232469e-03, -1.294384e-03, -1.353271e-03, -1.408390e-03, -1.458971e-03,
-		-1.504213e-03, -1.543293e-03, -1.575363e-03, -1.599563e-03, -1.615020e-03, -1.620853e-03, -1.616183e-03, -1.600132e-03, -1.571834e-03, -1.530437e-03,
-		-1.475111e-03, -1.405054e-03, -1.319494e-03, -1.217703e-03, -1.098992e-03, -9.627252e-04, -8.083232e-04, -6.352663e-04, -4.431020e-04, -2.314485e-04,
-		+4.705640e-19, +2.514691e-04, +5.231006e-04, +8.149488e-04, +1.126977e-03, +1.459057e-03, +1.810963e-03, +2.182373e-03, +2.572868e-03, +2.981928e-03,
-		+3.408937e-03, +3.853178e-03, +4.313840e-03, +4.790011e-03, +5.280692e-03, +5.784788e-03, +6.301120e-03, +6.828424e-03, +7.365355e-03, +7.910498e-03,
-		+8.462366e-03, +9.019408e-03, +9.580018e-03, +1.014254e-02, +1.070527e-02, +1.126648e-02, +1.182439e-02, +1.237723e-02, +1.292320e-02, +1.346049e-02,
-		+1.398730e-02, +1.450186e-02, +1.500240e-02, +1.548718e-02, +1.595450e-02, +1.640273e-02, +1.683025e-02, +1.723553e-02, +1.761711e-02, +1.797360e-02,
-		+1.830370e-02, +1.860619e-02, +1.887995e-02, +1.912397e-02, +1.933733e-02, +1.951925e-02, +1.966903e-02, +1.978611e-02, +1.987006e-02, +1.992056e-02,
-		+1.993741e-02, +1.992056e-02, +1.987006e-02, +1.978611e-02, +1.966903e-02, +1.951925e-02, +1.933733e-02, +1.912397e-02, +1.887995e-02, +1.860619e-02,
-		+1.830370e-02, +1.797360e-02, +1.761711e-02, +1.723553e-02, +1.683025e-02, +1.640273e-02, +1.595450e-02, +1.548718e-02, +1.500240e-02, +1.450186e-02,
-		+1.398730e-02, +1.346049e-02, +1.292320e-02, +1.237723e-02, +1.182439e-02, +1.126648e-02, +1.070527e-02, +1.014254e-02, +9.580018e-03, +9.019408e-03,
-		+8.462366e-03, +7.910498e-03, +7.365355e-03, +6.828424e-03, +6.301120e-03, +5.784788e-03, +5.280692e-03, +4.790011e-03, +4.313840e-03, +3.853178e-03,
-		+3.408937e-03, +2.981928e-03, +2.572868e-03, +2.182373e-03, +1.810963e-03, +1.459057e-03, +1.126977e-03, +8.149488e-04, +5.231006e-04, +2.514691e-04,
-		+4.705640e-19, -2.314485e-04, -4.431020e-04, -6.352663e-04, -8.083232e-04, -9.627252e-04, -1.098992e-03, -1.217703e-03, -1.319494e-03, -1.405054e-03,
-		-1.475111e-03, -1.530437e-03, -1.571834e-03, -1.600132e-03, -1.616183e-03, -1.620853e-03, -1.615020e-03, -1.599563e-03, -1.575363e-03, -1.543293e-03,
-		-1.504213e-03, -1.458971e-03, -1.408390e-03, -1.353271e-03, -1.294384e-03, -1.232469e-03, -1.168228e-03, -1.102326e-03, -1.035389e-03, -9.679970e-04,
-		-9.006876e-04, -8.339523e-04, -7.682357e-04, -7.039357e-04, -6.414028e-04, -5.809403e-04, -5.228055e-04, -4.672098e-04, -4.143206e-04, -3.642626e-04,
-		-3.171194e-04, -2.729361e-04, -2.317212e-04, -1.934493e-04, -1.580636e-04, -1.254791e-04, -9.558560e-05, -6.825031e-05, -4.332149e-05, -2.063151e-05,
-		-7.665721e-20, +1.876286e-05, +3.585321e-05, +5.147044e-05, +6.581415e-05, +7.908130e-05, +9.146344e-05, +1.031442e-04, +1.142967e-04, +1.250818e-04,
-		+1.356453e-04,
-	},
-	"2.5": {
-		+1.634104e-04, +1.777557e-04, +1.922096e-04, +2.068400e-04, +2.216880e-04, +2.367638e-04, +2.520438e-04, +2.674679e-04, +2.829374e-04, +2.983133e-04,
-		+3.134157e-04, +3.280232e-04, +3.418737e-04, +3.546651e-04, +3.660574e-04, +3.756757e-04, +3.831126e-04, +3.879331e-04, +3.896790e-04, +3.878740e-04,
-		+3.820302e-04, +3.716543e-04, +3.562546e-04, +3.353488e-04, +3.084717e-04, +2.751833e-04, +2.350773e-04, +1.877892e-04, +1.330056e-04, +7.047166e-05,
-		-2.322829e-19, -7.852157e-05, -1.651224e-04, -2.597420e-04, -3.622232e-04, -4.723067e-04, -5.896257e-04, -7.137015e-04, -8.439405e-04, -9.796316e-04,
-		-1.119945e-03, -1.263932e-03, -1.410527e-03, -1.558546e-03, -1.706696e-03, -1.853574e-03, -1.997677e-03, -2.137406e-03, -2.271077e-03, -2.396927e-03,
-		-2.513128e-03, -2.617798e-03, -2.709013e-03, -2.784818e-03, -2.843246e-03, -2.882331e-03, -2.900123e-03, -2.894702e-03, -2.864202e-03, -2.806818e-03,
-		-2.720830e-03, -2.604615e-03, -2.456667e-03, -2.275609e-03, -2.060214e-03, -1.809415e-03, -1.522319e-03, -1.198225e-03, -8.366299e-04, -4.372448e-04,
-		+7.112458e-19, +4.749442e-04, +9.871921e-04, +1.536107e-03, +2.120809e-03, +2.740173e-03, +3.392829e-03, +4.077162e-03, +4.791322e-03, +5.533224e-03,
-		+6.300558e-03, +7.090799e-03, +7.901218e-03, +8.728893e-03, +9.570727e-03, +1.042346e-02, +1.128370e-02, +1.214791e-02, +1.301248e-02, +1.387368e-02,
-		+1.472774e-02, +1.557087e-02, +1.639924e-02, +1.720903e-02, +1.799646e-02, +1.875782e-02, +1.948946e-02, +2.018785e-02, +2.084959e-02, +2.147142e-02,
-		+2.205027e-02, +2.258323e-02, +2.306764e-02, +2.350105e-02, +2.388125e-02, +2.420633e-02, +2.447461e-02, +2.468471e-02, +2.483556e-02, +2.492638e-02,
-		+2.495671e-02, +2.492638e-02, +2.483556e-02, +2.468471e-02, +2.447461e-02, +2.420633e-02, +2.388125e-02, +2.350105e-02, +2.306764e-02, +2.258323e-02,
-		+2.205027e-02, +2.147142e-02, +2.084959e-02, +2.018785e-02, +1.948946e-02, +1.875782e-02, +1.799646e-02, +1.720903e-02, +1.639924e-02, +1.557087e-02,
-		+1.472774e-02, +1.387368e-02, +1.301248e-02, +1.214791e-02, +1.128370e-02, +1.042346e-02, +9.570727e-03, +8.728893e-03, +7.901218e-03, +7.090799e-03,
-		+6.300558e-03, +5.533224e-03, +4.791322e-03, +4.077162e-03, +3.392829e-03, +2.740173e-03, +2.120809e-03, +1.536107e-03, +9.871921e-04, +4.749442e-04,
-		+7.112458e-19, -4.372448e-04, -8.366299e-04, -1.198225e-03, -1.522319e-03, -1.809415e-03, -2.060214e-03, -2.275609e-03, -2.456667e-03, -2.604615e-03,
-		-2.720830e-03, -2.806818e-03, -2.864202e-03, -2.894702e-03, -2.900123e-03, -2.882331e-03, -2.843246e-03, -2.784818e-03, -2.709013e-03, -2.617798e-03,
-		-2.513128e-03, -2.396927e-03, -2.271077e-03, -2.137406e-03, -1.997677e-03, -1.853574e-03, -1.706696e-03, -1.558546e-03, -1.410527e-03, -1.263932e-03,
-		-1.119945e-03, -9.796316e-04, -8.439405e-04, -7.137015e-04, -5.896257e-04, -4.723067e-04, -3.622232e-04, -2.597420e-04, -1.651224e-04, -7.852157e-05,
-		-2.322829e-19, +7.047166e-05, +1.330056e-04, +1.877892e-04, +2.350773e-04, +2.751833e-04, +3.084717e-04, +3.353488e-04, +3.562546e-04, +3.716543e-04,
-		+3.820302e-04, +3.878740e-04, +3.896790e-04, +3.879331e-04, +3.831126e-04, +3.756757e-04, +3.660574e-04, +3.546651e-04, +3.418737e-04, +3.280232e-04,
-		+3.134157e-04, +2.983133e-04, +2.829374e-04, +2.674679e-04, +2.520438e-04, +2.367638e-04, +2.216880e-04, +2.068400e-04, +1.922096e-04, +1.777557e-04,
-		+1.634104e-04,
-	},
-	"3.0": {
-		-1.871168e-04, -1.754947e-04, -1.627725e-04, -1.488071e-04, -1.334277e-04, -1.164413e-04, -9.763933e-05, -7.680524e-05, -5.372259e-05, -2.818394e-05,
-		+1.152426e-19, +3.099087e-05, +6.491310e-05, +1.018445e-04, +1.418071e-04, +1.847587e-04, +2.305846e-04, +2.790911e-04, +3.299993e-04, +3.829403e-04,
-		+4.374523e-04, +4.929786e-04, +5.488677e-04, +6.043751e-04, +6.586669e-04, +7.108250e-04, +7.598550e-04, +8.046952e-04, +8.442279e-04, +8.772923e-04,
-		+9.026992e-04, +9.192477e-04, +9.257421e-04, +9.210114e-04, +9.039290e-04, +8.734334e-04, +8.285493e-04, +7.684092e-04, +6.922747e-04, +5.995576e-04,
-		+4.898402e-04, +3.628945e-04, +2.187007e-04, +5.746357e-05, -1.203732e-04, -3.141141e-04, -5.228023e-04, -7.452118e-04, -9.798435e-04, -1.224924e-03,
-		-1.478406e-03, -1.737976e-03, -2.001061e-03, -2.264842e-03, -2.526269e-03, -2.782081e-03, -3.028826e-03, -3.262891e-03, -3.480526e-03, -3.677878e-03,
-		-3.851028e-03, -3.996021e-03, -4.108907e-03, -4.185786e-03, -4.222839e-03, -4.216379e-03, -4.162888e-03, -4.059061e-03, -3.901846e-03, -3.688484e-03,
-		-3.416551e-03, -3.083988e-03, -2.689144e-03, -2.230799e-03, -1.708196e-03, -1.121066e-03, -4.696448e-04, +2.453080e-04, +1.022500e-03, +1.860098e-03,
-		+2.755730e-03, +3.706485e-03, +4.708925e-03, +5.759097e-03, +6.852559e-03, +7.984395e-03, +9.149253e-03, +1.034138e-02, +1.155464e-02, +1.278260e-02,
-		+1.401854e-02, +1.525552e-02, +1.648640e-02, +1.770397e-02, +1.890093e-02, +2.006999e-02, +2.120392e-02, +2.229560e-02, +2.333812e-02, +2.432476e-02,
-		+2.524915e-02, +2.610523e-02, +2.688735e-02, +2.759034e-02, +2.820948e-02, +2.874063e-02, +2.918021e-02, +2.952524e-02, +2.977338e-02, +2.992294e-02,
-		+2.997291e-02, +2.992294e-02, +2.977338e-02, +2.952524e-02, +2.918021e-02, +2.874063e-02, +2.820948e-02, +2.759034e-02, +2.688735e-02, +2.610523e-02,
-		+2.524915e-02, +2.432476e-02, +2.333812e-02, +2.229560e-02, +2.120392e-02, +2.006999e-02, +1.890093e-02, +1.770397e-02, +1.648640e-02, +1.525552e-02,
-		+1.401854e-02, +1.278260e-02, +1.155464e-02, +1.034138e-02, +9.149253e-03, +7.984395e-03, +6.852559e-03, +5.759097e-03, +4.708925e-03, +3.706485e-03,
-		+2.755730e-03, +1.860098e-03, +1.022500e-03, +2.453080e-04, -4.696448e-04, -1.121066e-03, -1.708196e-03, -2.230799e-03, -2.689144e-03, -3.083988e-03,
-		-3.416551e-03, -3.688484e-03, -3.901846e-03, -4.059061e-03, -4.162888e-03, -4.216379e-03, -4.222839e-03, -4.185786e-03, -4.108907e-03, -3.996021e-03,
-		-3.851028e-03, -3.677878e-03, -3.480526e-03, -3.262891e-03, -3.028826e-03, -2.782081e-03, -2.526269e-03, -2.264842e-03, -2.001061e-03, -1.737976e-03,
-		-1.478406e-03, -1.224924e-03, -9.798435e-04, -7.452118e-04, -5.228023e-04, -3.141141e-04, -1.203732e-04, +5.746357e-05, +2.187007e-04, +3.628945e-04,
-		+4.898402e-04, +5.995576e-04, +6.922747e-04, +7.684092e-04, +8.285493e-04, +8.734334e-04, +9.039290e-04, +9.210114e-04, +9.257421e-04, +9.192477e-04,
-		+9.026992e-04, +8.772923e-04, +8.442279e-04, +8.046952e-04, +7.598550e-04, +7.108250e-04, +6.586669e-04, +6.043751e-04, +5.488677e-04, +4.929786e-04,
-		+4.374523e-04, +3.829403e-04, +3.299993e-04, +2.790911e-04, +2.305846e-04, +1.847587e-04, +1.418071e-04, +1.018445e-04, +6.491310e-05, +3.099087e-05,
-		+1.152426e-19, -2.818394e-05, -5.372259e-05, -7.680524e-05, -9.763933e-05, -1.164413e-04, -1.334277e-04, -1.488071e-04, -1.627725e-04, -1.754947e-04,
-		-1.871168e-04,
-	},
+func sinc(x float64) float64 {
+	if x == .0 {
+		return 1.0
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
 }
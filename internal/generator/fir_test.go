@@ -0,0 +1,140 @@
+package generator
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomWave(rnd *rand.Rand, n int) []float64 {
+	result := make([]float64, n)
+	for i := range result {
+		result[i] = rnd.NormFloat64()
+	}
+	return result
+}
+
+func TestConvolveFFT(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tests := []struct {
+		n    int
+		taps int
+	}{
+		{n: 0, taps: 8},
+		{n: 1, taps: 8},
+		{n: 10, taps: 64},  // 系列がフィルタより短い
+		{n: 63, taps: 64},  // 系列がフィルタより1サンプル短い
+		{n: 64, taps: 64},  // 系列とフィルタが同じ長さ
+		{n: 100, taps: 1},  // 1タップ
+		{n: 511, taps: 32}, // ブロック長ちょうど付近
+		{n: 1000, taps: 33},
+		{n: 5000, taps: 221},
+		{n: 20000, taps: 221},
+	}
+	for _, tt := range tests {
+		for trial := 0; trial < 3; trial++ {
+			wave := randomWave(rnd, tt.n)
+			if 0 < tt.n {
+				wave[0] += 10.0 // 先頭の値を延長する処理を確かめるため、直流成分を加える
+			}
+			ir := randomWave(rnd, tt.taps)
+			want := convolveDirect(wave, ir)
+			got := convolveFFT(wave, ir)
+			if len(got) != len(want) {
+				t.Fatalf("n=%d taps=%d: len = %d, want %d", tt.n, tt.taps, len(got), len(want))
+			}
+			for i := range want {
+				if 1e-9 < math.Abs(got[i]-want[i]) {
+					t.Errorf("n=%d taps=%d: result[%d] = %g, want %g", tt.n, tt.taps, i, got[i], want[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestUseFFTConvolve(t *testing.T) {
+	tests := []struct {
+		n    int
+		taps int
+		want bool
+	}{
+		{n: fftConvolveMinLength - 1, taps: 221, want: false},
+		{n: fftConvolveMinLength, taps: 221, want: true},
+		{n: fftConvolveMinLength, taps: fftConvolveMinTaps - 1, want: false},
+		{n: fftConvolveMinLength, taps: fftConvolveMinTaps, want: true},
+		{n: 100, taps: 10, want: false},
+	}
+	for _, tt := range tests {
+		if got := useFFTConvolve(tt.n, tt.taps); got != tt.want {
+			t.Errorf("useFFTConvolve(%d, %d) = %v, want %v", tt.n, tt.taps, got, tt.want)
+		}
+	}
+}
+
+func TestConvolveThreshold(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for _, n := range []int{fftConvolveMinLength - 1, fftConvolveMinLength} {
+		wave := randomWave(rnd, n)
+		ir := randomWave(rnd, fftConvolveMinTaps)
+		want := convolveDirect(wave, ir)
+		got := convolve(wave, ir)
+		for i := range want {
+			if 1e-9 < math.Abs(got[i]-want[i]) {
+				t.Errorf("n=%d: result[%d] = %g, want %g", n, i, got[i], want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestFiltfilt(t *testing.T) {
+	ir, err := designLPF(7.5, 1.0/notesFramePeriod, 221, "hamming")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ランプ: 位相遅れがなく、両端も垂れ下がらないこと
+	ramp := make([]float64, 2000)
+	for i := range ramp {
+		ramp[i] = 60.0 + float64(i)*.01
+	}
+	got := filtfilt(ramp, ir)
+	for i := range ramp {
+		if 1e-6 < math.Abs(got[i]-ramp[i]) {
+			t.Errorf("ramp: result[%d] = %g, want %g", i, got[i], ramp[i])
+			break
+		}
+	}
+
+	// ステップ: 段差の中点が移動しない（遅延がない）こと
+	step := make([]float64, 2000)
+	for i := range step {
+		step[i] = 60.0
+		if 1000 <= i {
+			step[i] = 62.0
+		}
+	}
+	got = filtfilt(step, ir)
+	for i := 1; i < len(got); i++ {
+		if got[i-1] < 61.0 && 61.0 <= got[i] {
+			if i < 999 || 1001 < i {
+				t.Errorf("step: midpoint crossed at %d, want 1000", i)
+			}
+		}
+	}
+	if math.Abs(got[0]-60.0) > 1e-6 || math.Abs(got[len(got)-1]-62.0) > 1e-6 {
+		t.Errorf("step: edges = %g, %g, want 60, 62", got[0], got[len(got)-1])
+	}
+
+	// フィルタ長より短い入力
+	for _, n := range []int{0, 1, 2, 3, 10, len(ir) - 1, len(ir)} {
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = 60.0 + float64(i)
+		}
+		if got := filtfilt(x, ir); len(got) != n {
+			t.Errorf("n=%d: len = %d", n, len(got))
+		}
+	}
+}
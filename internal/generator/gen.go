@@ -0,0 +1,137 @@
+package generator
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"path/filepath"
+	"strconv"
+
+	"github.com/but80/talklistener/internal/vsqx"
+	"github.com/but80/talklistener/internal/world"
+	"golang.org/x/xerrors"
+)
+
+const (
+	genCenter          = 64
+	genPerSemitone     = 8.0    // フォルマント1半音あたりのGEN値の変化量
+	genRefCentroid     = 1000.0 // 参照音声を指定しない場合の基準（成人女性の話し声のおおよその値, Hz）
+	genCentroidMinFreq = 300.0
+	genCentroidMaxFreq = 5000.0
+	genRefSingerNote   = 65  // genRefCentroid に対応するシンガーの推奨音域の中央（女性シンガーの G2〜E4）
+	genFormantPerPitch = .25 // 推奨音域1半音あたりのフォルマントの変化量（成人の男女差：音高約1オクターブに対しフォルマント約3半音）
+)
+
+// GENReferenceSinger は、GEN推定の基準としてシンガーの推奨音域を指定する値です。
+const GENReferenceSinger = "singer"
+
+// GENModes は、GENの出力方法として指定可能な値の一覧です。
+var GENModes = []string{
+	"param",
+	"ctrl",
+}
+
+// spectralCentroid は、平均のスペクトル包絡 avg から、対数周波数軸上の重心（Hz）を求めます。
+func spectralCentroid(avg []float64, fftSize, fs int) float64 {
+	sum := .0
+	sumLogF := .0
+	for j, p := range avg {
+		f := float64(j) * float64(fs) / float64(fftSize)
+		if f < genCentroidMinFreq || genCentroidMaxFreq < f {
+			continue
+		}
+		sum += p
+		sumLogF += p * math.Log(f)
+	}
+	if sum <= .0 {
+		return .0
+	}
+	return math.Exp(sumLogF / sum)
+}
+
+// estimateFormantCentroid は、音声ファイルのスペクトル包絡の重心を推定します。
+// f0 は framePeriod 間隔の基本周波数（Hz）です。
+func estimateFormantCentroid(wavfile string, f0 []float64, framePeriod float64) (float64, error) {
+	x, fs, err := loadWav(wavfile)
+	if err != nil {
+		return .0, xerrors.Errorf("音声ファイルの読み込みに失敗しました: %w", err)
+	}
+	if len(f0) == 0 {
+		return .0, xerrors.New("基本周波数がありません")
+	}
+	avg, fftSize := world.CheapTrickMean(x, fs, framePeriod, f0)
+	c := spectralCentroid(avg, fftSize, fs)
+	if c <= .0 {
+		return .0, xerrors.New("スペクトル包絡の重心を求められません")
+	}
+	return c, nil
+}
+
+// singerCentroid は、シンガーの推奨音域の中央から、スペクトル包絡の重心を見積もります。
+func singerCentroid(singer string) (float64, bool) {
+	r, ok := vsqx.SingerRange(singer)
+	if !ok {
+		return .0, false
+	}
+	shift := float64(r.Center()-genRefSingerNote) * genFormantPerPitch
+	return genRefCentroid * math.Pow(2.0, shift/12.0), true
+}
+
+// refCacheFile は、参照音声ファイル ref をフォーマット変換したファイルの名前を返します。
+// 参照音声ファイルを変更したときに古い変換結果を使わないよう、ファイル名にパスのハッシュを含めます。
+func refCacheFile(objPrefix, ref string) string {
+	if abs, err := filepath.Abs(ref); err == nil {
+		ref = abs
+	}
+	h := fnv.New32a()
+	h.Write([]byte(ref))
+	return fmt.Sprintf("%s.ref.%08x.wav", objPrefix, h.Sum32())
+}
+
+// referenceCentroid は、GEN推定の基準となるスペクトル包絡の重心を求めます。
+// ref には周波数（Hz）、参照音声ファイル名、または GENReferenceSinger を指定します。
+// 参照音声ファイルは objPrefix から始まる名前のファイルにフォーマット変換してから解析します。
+func referenceCentroid(ref, singer, objPrefix string) (float64, error) {
+	if ref == "" {
+		return genRefCentroid, nil
+	}
+	if ref == GENReferenceSinger {
+		c, ok := singerCentroid(singer)
+		if !ok {
+			log.Printf("warn: シンガー %s の推奨音域が定義されていないため、既定の基準値を用います", singer)
+			return genRefCentroid, nil
+		}
+		log.Printf("info: シンガー %s の推奨音域から見積もった基準値: %.1f Hz", singer, c)
+		return c, nil
+	}
+	if f, err := strconv.ParseFloat(ref, 64); err == nil {
+		return f, nil
+	}
+	convertedFile := refCacheFile(objPrefix, ref)
+	if !isNewer(convertedFile, ref) {
+		if err := convertAudioFile(ref, convertedFile, "", 0, nil); err != nil {
+			return .0, xerrors.Errorf("参照音声ファイルの変換に失敗しました: %w", err)
+		}
+	}
+	x, fs, err := loadWav(convertedFile)
+	if err != nil {
+		return .0, xerrors.Errorf("参照音声ファイルの読み込みに失敗しました: %w", err)
+	}
+	f0 := interpolate(world.Harvest(x, fs, f0FramePeriod))
+	return estimateFormantCentroid(convertedFile, f0, f0FramePeriod)
+}
+
+// formantShiftToGEN は、基準に対するフォルマントの高さの比をGEN値に変換します。
+// フォルマントが基準より低いほど、GENは大きく（太い声に）なります。
+func formantShiftToGEN(centroid, reference float64) int {
+	shift := 12.0 * math.Log2(centroid/reference)
+	gen := int(math.Round(genCenter - shift*genPerSemitone))
+	if gen < 0 {
+		gen = 0
+	} else if 127 < gen {
+		gen = 127
+	}
+	log.Printf("debug: formant centroid = %.1f Hz, reference = %.1f Hz, shift = %+.2f semitones", centroid, reference, shift)
+	return gen
+}
@@ -1,6 +1,8 @@
 package generator
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -25,29 +27,31 @@ const (
 	bpm              = 125.00
 	tickTime         = 60.0 / bpm / float64(resolution) // = 0.001
 	parallel         = true
-	extendNoteTime   = 0.025
-	shiftBendTime    = 0.0
-	baseF0Delay      = 0.035
-	durationRatio    = .5
+	longVowelHead    = 0.12 // 長音を "ー" のノートに分割する場合の、先頭のノートの最大長（秒）
+	juliusSampleRate = 16000
+	stdio            = "-"     // 標準入出力を表すファイル名
+	stdinName        = "stdin" // 標準入力から読み込んだ音声のキャッシュ上の名前
+	stdinHashLength  = 12      // キャッシュ上の名前に含める、標準入力の内容のハッシュの桁数
 )
 
 func timeToTick(time float64) int {
 	return int(math.Round(time / tickTime))
 }
 
-func durationToVelocity(dur float64) int {
-	velocity := int(math.Round(16.0 - (math.Log10(dur)+0.622511616623867)*160.8972722*durationRatio))
-	if velocity < 1 {
-		velocity = 1
-	} else if 127 < velocity {
-		velocity = 127
-	}
-	return velocity
+// LongVowelModes は、長音の出力方法として指定可能な値の一覧です。
+// "extend" は1つのノートを伸ばし、"bar" は "ー" のノートを続けて出力します。
+var LongVowelModes = []string{
+	"extend",
+	"bar",
 }
 
 type generator struct {
 	noteCenter int
 	vsqx       *vsqx.VSQ3
+	track      *vsqx.VSTrack
+	longVowel  string
+	devoice    string
+	profile    *Profile
 
 	consonant          string
 	consonantBeginTime float64
@@ -55,6 +59,9 @@ type generator struct {
 	vowel              string
 	vowelBeginTime     float64
 	vowelEndTime       float64
+	vowelLong          bool
+	vowelDevoiced      bool
+	vowelVibrato       *vibrato
 }
 
 func (gen *generator) reset() {
@@ -64,6 +71,9 @@ func (gen *generator) reset() {
 	gen.vowel = ""
 	gen.vowelBeginTime = -1.0
 	gen.vowelEndTime = -1.0
+	gen.vowelLong = false
+	gen.vowelDevoiced = false
+	gen.vowelVibrato = nil
 }
 
 func (gen *generator) setConsonant(begin, end float64, unit string) {
@@ -72,10 +82,11 @@ func (gen *generator) setConsonant(begin, end float64, unit string) {
 	gen.consonantEndTime = end
 }
 
-func (gen *generator) setVowel(begin, end float64, unit string) {
+func (gen *generator) setVowel(begin, end float64, unit string, long bool) {
 	gen.vowelBeginTime = begin
 	gen.vowelEndTime = end
 	gen.vowel = unit
+	gen.vowelLong = long
 }
 
 func (gen *generator) flush() error {
@@ -97,53 +108,107 @@ func (gen *generator) flush() error {
 	if gen.consonant == "" || gen.consonantBeginTime < .0 {
 		if gen.vowel == "" || gen.vowelBeginTime < .0 {
 			// 何もない
+		} else if gen.vowelDevoiced && gen.devoice == "consonant" {
+			// 無声化した母音のみ（子音を分割配置した場合。直前の子音のノートを伸ばす）
+			gen.track.ExtendLastNote(timeToTick(gen.vowelEndTime+gen.profile.ExtendNoteTime), timeToTick(gen.vowelBeginTime))
 		} else {
 			// 母音のみ
-			gen.vsqx.AddNote(
+			lyrics := julius.Consonants[""].Kana[vowelIndex]
+			phnms := ""
+			if gen.vowelDevoiced && gen.devoice == "phoneme" {
+				phnms, _ = vsqx.DevoicedPhonemes(lyrics)
+			}
+			gen.track.AddNote(
 				64,
 				timeToTick(gen.vowelBeginTime),
-				timeToTick(gen.vowelEndTime+extendNoteTime),
+				timeToTick(gen.vowelEndTime+gen.profile.ExtendNoteTime),
 				gen.noteCenter,
-				julius.Consonants[""].Kana[vowelIndex],
-				"",
+				lyrics,
+				phnms,
 			)
+			gen.addLongVowel()
 		}
 	} else {
 		if gen.vowel == "" || gen.vowelBeginTime < .0 {
 			// 子音のみ
-			gen.vsqx.AddNote(
-				durationToVelocity(gen.consonantEndTime-gen.consonantBeginTime),
+			gen.track.AddNote(
+				gen.profile.velocity(gen.consonantEndTime-gen.consonantBeginTime),
+				timeToTick(gen.consonantBeginTime),
+				timeToTick(gen.consonantEndTime+gen.profile.ExtendNoteTime),
+				gen.noteCenter,
+				gen.consonant,
+				cons.VSQXPhoneme,
+			)
+		} else if gen.vowelDevoiced && gen.devoice == "consonant" {
+			// 子音＋無声化した母音（子音のみのノートとして母音の区間まで伸ばす）
+			gen.track.AddNote(
+				gen.profile.velocity(gen.vowelBeginTime-gen.consonantBeginTime),
 				timeToTick(gen.consonantBeginTime),
-				timeToTick(gen.consonantEndTime+extendNoteTime),
+				timeToTick(gen.vowelEndTime+gen.profile.ExtendNoteTime),
 				gen.noteCenter,
 				gen.consonant,
 				cons.VSQXPhoneme,
 			)
 		} else {
-			// 子音＋母音
-			begin := timeToTick(gen.vowelBeginTime)
-			end := timeToTick(gen.vowelEndTime + extendNoteTime)
-			gen.vsqx.ExtendLastNote(begin, timeToTick(gen.consonantBeginTime))
-			gen.vsqx.AddNote(
-				durationToVelocity(gen.vowelBeginTime-gen.consonantBeginTime),
+			// 子音＋母音（子音の種類に応じて、子音の区間内で開始位置を早める）
+			class := vsqx.ConsonantClass(cons.VSQXPhoneme)
+			beginTime := math.Max(gen.vowelBeginTime-gen.profile.preUtterance(class), gen.consonantBeginTime)
+			begin := timeToTick(beginTime)
+			end := timeToTick(gen.vowelEndTime + gen.profile.ExtendNoteTime)
+			lyrics := cons.Kana[vowelIndex]
+			phnms := ""
+			if gen.vowelDevoiced && gen.devoice == "phoneme" {
+				phnms, _ = vsqx.DevoicedPhonemes(lyrics)
+			}
+			gen.track.ExtendLastNote(begin, timeToTick(gen.consonantBeginTime))
+			gen.track.AddNote(
+				gen.profile.velocity(gen.vowelBeginTime-gen.consonantBeginTime),
 				begin,
 				end,
 				gen.noteCenter,
-				cons.Kana[vowelIndex],
-				"",
+				lyrics,
+				phnms,
 			)
+			gen.addLongVowel()
 		}
 	}
 	gen.reset()
 	return nil
 }
 
+// addLongVowel は、長音を "ー" のノートとして出力する場合に、直前の母音のノートを分割して "ー" のノートを続けます。
+// ビブラートは、母音を伸ばしている側のノートに設定します。
+func (gen *generator) addLongVowel() {
+	if !gen.vowelLong || gen.longVowel != "bar" {
+		gen.applyVibrato()
+		return
+	}
+	split := math.Min(gen.vowelBeginTime+longVowelHead, (gen.vowelBeginTime+gen.vowelEndTime)/2.0)
+	gen.track.AddNote(
+		64,
+		timeToTick(split),
+		timeToTick(gen.vowelEndTime+gen.profile.ExtendNoteTime),
+		gen.noteCenter,
+		julius.LongVowelKana,
+		"",
+	)
+	gen.applyVibrato()
+}
+
+func (gen *generator) applyVibrato() {
+	v := gen.vowelVibrato
+	if v == nil {
+		return
+	}
+	gen.track.SetLastNoteVibrato(v.length, vibratoType, v.vsqxDepth(), v.vsqxRate())
+}
+
 func (gen *generator) feedPitchBends(notes []float64, timeOffset float64) {
 	bendSense := 24
-	gen.vsqx.AddMCtrl(.0, "PBS", bendSense)
 	t := timeOffset
 	last := 0
-	for i, note := range notes {
+	var part *vsqx.MusicalPart
+	for _, note := range notes {
 		tick := int(math.Round(t / tickTime))
 		dNote := note - float64(gen.noteCenter)
 		bend := int(math.Round(8192.0 * dNote / float64(bendSense)))
@@ -152,27 +217,36 @@ func (gen *generator) feedPitchBends(notes []float64, timeOffset float64) {
 		} else if 8191 < bend {
 			bend = 8191
 		}
-		if i == 0 || last != bend {
-			gen.vsqx.AddMCtrl(tick, "PIT", bend)
+		p := gen.track.PartAt(tick)
+		if p != part {
+			// パートの先頭ごとにベンド幅と現在値を設定する
+			gen.track.AddMCtrl(p.BeginTick(), "PBS", bendSense)
+		}
+		if p != part || last != bend {
+			gen.track.AddMCtrl(tick, "PIT", bend)
 		}
+		part = p
 		last = bend
 		t += notesFramePeriod
 	}
 }
 
-func (gen *generator) save(filename string) error {
-	return ioutil.WriteFile(filename, gen.vsqx.Bytes(), 0644)
-}
-
-func (gen *generator) dump() {
-	fmt.Println(string(gen.vsqx.Bytes()))
-}
-
-func lerp(a, b, r float64) float64 {
-	return a*(1.0-r) + b*r
+// audioChannels は、音声ファイルのチャンネル数を返します。
+func audioChannels(in string) (int, error) {
+	var info sndfile.Info
+	f, err := sndfile.Open(in, sndfile.Read, &info)
+	if err != nil {
+		return 0, xerrors.Errorf("Failed to open input file: %w", err)
+	}
+	defer f.Close()
+	return int(info.Channels), nil
 }
 
-func convertAudioFile(in, out string) error {
+// convertAudioFile は、音声ファイルを 16kHz モノラルの32bit浮動小数点wavファイル out に変換します。
+// juliusOut を指定すると、Julius に入力するための16bit整数wavファイルも併せて出力します。
+// channel に 1 以上を指定するとそのチャンネルのみを、0 を指定すると全チャンネルを平均して出力します。
+// pre を指定すると、16kHz に変換した後に前処理を施します。
+func convertAudioFile(in, out, juliusOut string, channel int, pre *PreprocessOptions) error {
 	log.Print("info: 音声ファイルのフォーマットを変換中...")
 
 	var inInfo sndfile.Info
@@ -194,7 +268,16 @@ func convertAudioFile(in, out string) error {
 		return xerrors.Errorf("Failed to read file (%d != %d): %s", n, n0, in)
 	}
 
-	if 1 < ch {
+	if ch < channel {
+		return xerrors.Errorf("チャンネル %d は存在しません（チャンネル数: %d）: %s", channel, ch, in)
+	}
+	if 0 < channel {
+		source0 := source
+		source = make([]float64, n0)
+		for i := 0; i < n0; i++ {
+			source[i] = source0[i*ch+channel-1]
+		}
+	} else if 1 < ch {
 		source0 := source
 		source = make([]float64, n0)
 		for i := 0; i < n0; i++ {
@@ -205,28 +288,32 @@ func convertAudioFile(in, out string) error {
 		}
 	}
 
+	// Julius の音響モデルに合わせて 16kHz に変換
 	sampleRate := int(inInfo.Samplerate)
-	n1 := n0
 	dest := source
-	if 16000 < sampleRate {
-		sampleRate = 16000
-		n1 = int(math.Round(float64(n0) * float64(sampleRate) / float64(inInfo.Samplerate)))
-		dest = make([]float64, n1)
-		for i1 := 0; i1 < n1; i1++ {
-			i0 := float64(i1) * float64(n0) / float64(n1)
-			i0i := int(i0)
-			i0f := i0 - float64(i0i)
-			dest[i1] = lerp(source[i0i], source[i0i+1], i0f)
+	if sampleRate != juliusSampleRate {
+		dest = resampleAudio(source, sampleRate, juliusSampleRate)
+		sampleRate = juliusSampleRate
+	}
+	dest = preprocess(dest, sampleRate, pre)
+
+	if err := writeWav(out, dest, sampleRate, sndfile.SF_FORMAT_FLOAT); err != nil {
+		return err
+	}
+	if juliusOut != "" {
+		if err := writeWav(juliusOut, dest, sampleRate, sndfile.SF_FORMAT_PCM_16); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
+func writeWav(out string, dest []float64, sampleRate int, format sndfile.Format) error {
 	outInfo := sndfile.Info{
-		Frames:     int64(n1),
+		Frames:     int64(len(dest)),
 		Samplerate: int32(sampleRate),
 		Channels:   1,
-		Format:     sndfile.SF_FORMAT_WAV | sndfile.SF_FORMAT_PCM_16,
-		Sections:   inInfo.Sections,
-		Seekable:   inInfo.Seekable,
+		Format:     sndfile.SF_FORMAT_WAV | format,
 	}
 	fout, err := sndfile.Open(out, sndfile.Write, &outInfo)
 	if err != nil {
@@ -267,73 +354,371 @@ func isEmpty(filename string) bool {
 	return err != nil || s.Size() == 0
 }
 
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 var removeExtRx = regexp.MustCompile(`\.[^\.]+$`)
 
 func removeExt(filename string) string {
 	return removeExtRx.ReplaceAllString(filename, "")
 }
 
+// insertSuffix は、ファイル名の拡張子の前に suffix を挿入します。
+func insertSuffix(filename, suffix string) string {
+	if suffix == "" {
+		return filename
+	}
+	return removeExt(filename) + suffix + filepath.Ext(filename)
+}
+
 type GenerateOptions struct {
-	AudioFile      string
-	TextFile       string
-	OutFile        string
-	Singer         string
-	F0LPFCutoff    string
-	F0Delay        float64
-	DictationModel string
-	SplitConsonant bool
-	Transpose      int
-	Redictate      bool
-	Recache        bool
+	AudioFile       string
+	TextFile        string
+	OutFile         string
+	TextGridFile    string
+	SRTFile         string
+	CacheDir        string
+	Singer          string
+	SingersFile     string
+	Channel         int
+	SplitChannels   bool
+	F0LPFCutoff     float64
+	F0LPFTaps       int
+	F0LPFWindow     string
+	F0Filter        string
+	F0Delay         float64
+	DictationModel  string
+	SplitConsonant  bool
+	Transpose       int
+	TransposeTo     string
+	TransposeOctave bool
+	GENMode         string
+	GENReference    string
+	Vibrato         bool
+	ChunkLength     float64
+	PartSplit       string
+	PartPause       float64
+	LongVowel       string
+	Devoice         string
+	Profile         string
+	UserDict        string
+	LearnDict       bool
+	Redictate       bool
+	Recache         bool
+	F0Cleanup       F0CleanupOptions
+	Preprocess      PreprocessOptions
+}
+
+// LoadSingers は、シンガー定義ファイルを読み込みます。
+// filename を省略した場合は、既定のシンガー定義ファイルがあれば読み込みます。
+func LoadSingers(filename string) error {
+	if filename == "" {
+		filename = vsqx.DefaultSingersFile()
+		if filename == "" || !exists(filename) {
+			return nil
+		}
+	}
+	names, err := vsqx.LoadSingers(filename)
+	if err != nil {
+		return err
+	}
+	log.Printf("info: シンガー定義ファイルから %d 件のシンガーを読み込みました: %s", len(names), filename)
+	return nil
 }
 
 // Generate は、話し声を録音した音声ファイルからVocaloid3シーケンスを生成します。
 func Generate(opts *GenerateOptions) error {
-	if !vsqx.IsValidSinger(opts.Singer) {
-		log.Printf("warn: シンガー %s は定義されていません", opts.Singer)
-		opts.Singer = vsqx.DefaultSinger
+	if err := LoadSingers(opts.SingersFile); err != nil {
+		return xerrors.Errorf("シンガー定義ファイルの読み込みに失敗しました: %w", err)
 	}
-
-	if p, err := filepath.Abs(opts.AudioFile); err == nil {
-		opts.AudioFile = p
+	singers := strings.Split(opts.Singer, ",")
+	for i, singer := range singers {
+		if !vsqx.IsValidSinger(singer) {
+			log.Printf("warn: シンガー %s は定義されていません", singer)
+			singers[i] = vsqx.DefaultSinger
+		} else if vsqx.IsEnglishSinger(singer) {
+			log.Printf("info: シンガー %s は英語ライブラリのため、発音記号を英語の発音記号に変換して出力します", singer)
+		}
 	}
-	if !exists(opts.AudioFile) {
-		return fmt.Errorf("%s が見つかりません", opts.AudioFile)
+	if opts.F0Filter != "" && !contains(F0FilterModes, opts.F0Filter) {
+		return fmt.Errorf("フィルタの方式 %s は定義されていません", opts.F0Filter)
 	}
-
-	if opts.TextFile == "" {
-		opts.TextFile = removeExt(opts.AudioFile) + ".txt"
-	} else if p, err := filepath.Abs(opts.TextFile); err == nil {
-		opts.TextFile = p
+	warnLegacyLPFCutoff(opts.F0LPFCutoff)
+	if opts.GENMode != "" && !contains(GENModes, opts.GENMode) {
+		return fmt.Errorf("GENの出力方法 %s は定義されていません", opts.GENMode)
+	}
+	if opts.Devoice != "" && !contains(DevoiceModes, opts.Devoice) {
+		return fmt.Errorf("無声化した母音の出力方法 %s は定義されていません", opts.Devoice)
+	}
+	if opts.LongVowel != "" && !contains(LongVowelModes, opts.LongVowel) {
+		return fmt.Errorf("長音の出力方法 %s は定義されていません", opts.LongVowel)
+	}
+	if opts.TransposeTo != "" && opts.TransposeTo != TransposeToSinger {
+		if _, err := parseNoteName(opts.TransposeTo); err != nil {
+			return xerrors.Errorf("移調先が不正です: %w", err)
+		}
+	}
+	if opts.PartSplit != "" && !contains(PartSplitModes, opts.PartSplit) {
+		return fmt.Errorf("パートの分割方法 %s は定義されていません", opts.PartSplit)
+	}
+	if err := opts.F0Cleanup.validate(); err != nil {
+		return err
+	}
+	if err := opts.Preprocess.validate(); err != nil {
+		return err
+	}
+	var dict *julius.UserDictionary
+	if opts.UserDict != "" {
+		var err error
+		dict, err = julius.LoadUserDictionary(opts.UserDict)
+		if err != nil {
+			return xerrors.Errorf("ユーザー辞書の読み込みに失敗しました: %w", err)
+		}
+		log.Printf("info: ユーザー辞書から %d 件の読みを読み込みました", dict.Len())
+	} else if opts.LearnDict {
+		return fmt.Errorf("--learn-dict には --dict でユーザー辞書を指定する必要があります")
 	}
 
-	if opts.OutFile == "" {
-		opts.OutFile = removeExt(opts.AudioFile) + ".vsqx"
-	} else if p, err := filepath.Abs(opts.OutFile); err == nil {
-		opts.OutFile = p
+	fromStdin := opts.AudioFile == stdio
+	var stdinData []byte
+	if fromStdin {
+		var err error
+		stdinData, err = readStdin()
+		if err != nil {
+			return xerrors.Errorf("標準入力からの音声の読み込みに失敗しました: %w", err)
+		}
+		// 異なる音声のキャッシュ（自動認識したテキスト等）を再利用しないよう、内容のハッシュを名前に含める
+		hash := fmt.Sprintf("%x", sha1.Sum(stdinData))
+		opts.AudioFile = stdinName + "-" + hash[:stdinHashLength]
+		if opts.CacheDir == "" {
+			tmpdir, err := ioutil.TempDir("", "talklistener")
+			if err != nil {
+				return xerrors.Errorf("一時ディレクトリの作成に失敗しました: %w", err)
+			}
+			defer os.RemoveAll(tmpdir)
+			opts.CacheDir = tmpdir
+		}
+	} else {
+		if p, err := filepath.Abs(opts.AudioFile); err == nil {
+			opts.AudioFile = p
+		}
+		if !exists(opts.AudioFile) {
+			return fmt.Errorf("%s が見つかりません", opts.AudioFile)
+		}
 	}
 
 	name := filepath.Base(opts.AudioFile)
 	objdir := removeExt(opts.AudioFile) + ".tlo"
+	if opts.CacheDir != "" {
+		objdir = filepath.Join(opts.CacheDir, removeExt(name)+".tlo")
+	}
+	if p, err := filepath.Abs(objdir); err == nil {
+		objdir = p
+	}
 	if opts.Recache {
 		if err := os.RemoveAll(objdir); err != nil {
 			return xerrors.Errorf("キャッシュディレクトリの作成に失敗しました: %w", err)
 		}
 	}
 	if _, err := os.Stat(objdir); err != nil {
-		if err := os.Mkdir(objdir, 0755); err != nil {
+		if err := os.MkdirAll(objdir, 0755); err != nil {
 			return xerrors.Errorf("キャッシュディレクトリの作成に失敗しました: %w", err)
 		}
 	}
 	objPrefix := filepath.Join(objdir, name)
 
+	if fromStdin {
+		// 標準入力の内容はキャッシュディレクトリ内に保存して、以降はファイルとして扱う
+		opts.AudioFile = objPrefix
+		if err := saveStdin(opts.AudioFile, stdinData); err != nil {
+			return xerrors.Errorf("標準入力から読み込んだ音声の保存に失敗しました: %w", err)
+		}
+	}
+
+	if opts.TextFile == "" {
+		if fromStdin {
+			opts.TextFile = objPrefix + ".txt"
+		} else {
+			opts.TextFile = removeExt(opts.AudioFile) + ".txt"
+		}
+	} else if p, err := filepath.Abs(opts.TextFile); err == nil {
+		opts.TextFile = p
+	}
+
+	if opts.OutFile == "" {
+		if fromStdin {
+			opts.OutFile = stdio
+		} else {
+			opts.OutFile = removeExt(opts.AudioFile) + ".vsqx"
+		}
+	} else if opts.OutFile == stdio {
+		// 標準出力
+	} else if p, err := filepath.Abs(opts.OutFile); err == nil {
+		opts.OutFile = p
+	}
+
+	if !opts.SplitChannels {
+		prefix := objPrefix
+		if 0 < opts.Channel {
+			prefix += fmt.Sprintf(".ch%d", opts.Channel)
+		}
+		vsq := vsqx.New(singers[0], resolution, bpm)
+		if err := generateTrack(opts, dict, vsq, vsq.VSTrack[0], opts.Channel, prefix, ""); err != nil {
+			return err
+		}
+		return saveVSQX(vsq, opts.OutFile)
+	}
+
+	n, err := audioChannels(opts.AudioFile)
+	if err != nil {
+		return xerrors.Errorf("音声ファイルの読み込みに失敗しました: %w", err)
+	}
+	var vsq *vsqx.VSQ3
+	for ch := 1; ch <= n; ch++ {
+		singer := singers[len(singers)-1]
+		if ch <= len(singers) {
+			singer = singers[ch-1]
+		}
+		var track *vsqx.VSTrack
+		if vsq == nil {
+			vsq = vsqx.New(singer, resolution, bpm)
+			track = vsq.VSTrack[0]
+		} else {
+			track = vsq.AddTrack(singer)
+		}
+		track.TrackName.Data = fmt.Sprintf("Ch%d", ch)
+		log.Printf("info: チャンネル %d / %d を処理中（シンガー: %s）", ch, n, singer)
+		suffix := fmt.Sprintf(".ch%d", ch)
+		if err := generateTrack(opts, dict, vsq, track, ch, objPrefix+suffix, suffix); err != nil {
+			return xerrors.Errorf("チャンネル %d の処理に失敗しました: %w", ch, err)
+		}
+	}
+	return saveVSQX(vsq, opts.OutFile)
+}
+
+// readStdin は、標準入力の内容を読み込みます。
+func readStdin() ([]byte, error) {
+	b, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, xerrors.New("標準入力が空です")
+	}
+	return b, nil
+}
+
+// saveStdin は、標準入力から読み込んだ内容 b をファイルに保存します。
+// 内容が既存のファイルと同一の場合は、キャッシュを活かすため更新しません。
+func saveStdin(filename string, b []byte) error {
+	if old, err := ioutil.ReadFile(filename); err == nil && bytes.Equal(old, b) {
+		return nil
+	}
+	return ioutil.WriteFile(filename, b, 0644)
+}
+
+// recognize は、音声ファイル全体から発話内容と発音タイミングを推定します。
+// テキストファイルが空の場合は、推定した発話内容をテキストファイルに保存します。
+func recognize(opts *GenerateOptions, dict *julius.UserDictionary, wavFile, textFile, objPrefix string) (*julius.Result, error) {
+	lastSec := -1
+	julius.OnProgress = func(progress, total float64) {
+		sec := int(progress/10.0) * 10
+		if lastSec != sec {
+			log.Printf("info: 進捗: %d / %d 秒", sec, int(math.Ceil(total)))
+			lastSec = sec
+		}
+	}
+	if isEmpty(textFile) || opts.Redictate {
+		result, err := julius.Dictate(wavFile, opts.DictationModel)
+		if err != nil {
+			return nil, xerrors.Errorf("発話内容の推定に失敗しました: %w", err)
+		}
+		b := []byte(result.DictationString())
+		if len(b) == 0 {
+			return nil, xerrors.Errorf("音声ファイル中に認識可能な発話がありませんでした")
+		}
+		if err := ioutil.WriteFile(textFile, b, 0644); err != nil {
+			return nil, xerrors.Errorf("推定した発話内容の保存に失敗しました: %w", err)
+		}
+		if err := learnDictionary(opts, dict, result); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Print("info: 発話内容をテキストファイルから読み込みます")
+	}
+	result, err := julius.Segmentate(wavFile, textFile, objPrefix, dict)
+	if err != nil {
+		return nil, xerrors.Errorf("発音タイミングの推定に失敗しました: %w", err)
+	}
+	return result, nil
+}
+
+// learnDictionary は、--learn-dict が指定されている場合に、発話内容の推定結果の読みをユーザー辞書に追加します。
+func learnDictionary(opts *GenerateOptions, dict *julius.UserDictionary, result *julius.Result) error {
+	if !opts.LearnDict || dict == nil {
+		return nil
+	}
+	n, err := dict.Learn(result)
+	if err != nil {
+		return xerrors.Errorf("ユーザー辞書の保存に失敗しました: %w", err)
+	}
+	if 0 < n {
+		log.Printf("info: ユーザー辞書に %d 件の読みを追加しました", n)
+	}
+	return nil
+}
+
+func saveVSQX(vsq *vsqx.VSQ3, filename string) error {
+	if filename == stdio {
+		if _, err := os.Stdout.Write(vsq.Bytes()); err != nil {
+			return xerrors.Errorf("VSQXの出力に失敗しました: %w", err)
+		}
+	} else if err := ioutil.WriteFile(filename, vsq.Bytes(), 0644); err != nil {
+		return xerrors.Errorf("VSQXの保存に失敗しました: %w", err)
+	}
+	log.Printf("info: 出力ノート数: %d", vsq.NoteCount())
+	log.Print("info: 完了")
+	return nil
+}
+
+// generateTrack は、音声ファイルの指定チャンネルから track にノートとピッチベンドを生成します。
+// 中間ファイルは objPrefix で始まる名前で保存されます。
+// suffix を指定すると、テキストファイル等の名前の拡張子の前に suffix を付加します。
+func generateTrack(opts *GenerateOptions, dict *julius.UserDictionary, vsq *vsqx.VSQ3, track *vsqx.VSTrack, channel int, objPrefix, suffix string) error {
+	textFile := opts.TextFile
+	if suffix != "" {
+		textFile = removeExt(opts.TextFile) + suffix + ".txt"
+	}
+
+	singer := vsq.Voice(track).VoiceName.Data
+	if opts.Profile == "" && hasProfilePreset(singer) {
+		log.Printf("info: シンガー %s のプリセットは --profile %s で使用できます", singer, singer)
+	}
+	profile, err := loadProfile(opts.Profile)
+	if err != nil {
+		return xerrors.Errorf("ノートの生成規則の読み込みに失敗しました: %w", err)
+	}
+	profile.mergeSingerPreUtterance(singer)
+	profile.apply(track)
+
 	convertedWavFile := objPrefix + ".wav"
-	if isNewer(convertedWavFile, opts.AudioFile) {
+	juliusWavFile := objPrefix + ".pcm16.wav"
+	preprocessFile := objPrefix + ".wav.json"
+	if isNewer(convertedWavFile, opts.AudioFile) && isNewer(juliusWavFile, opts.AudioFile) && matchPreprocessOptions(preprocessFile, &opts.Preprocess) {
 		log.Printf("info: フォーマット変換済み音声ファイルのキャッシュを使用します: %s", convertedWavFile)
 	} else {
-		if err := convertAudioFile(opts.AudioFile, convertedWavFile); err != nil {
+		if err := convertAudioFile(opts.AudioFile, convertedWavFile, juliusWavFile, channel, &opts.Preprocess); err != nil {
 			return xerrors.Errorf("音声ファイルの変換に失敗しました: %w", err)
 		}
+		if err := savePreprocessOptions(preprocessFile, &opts.Preprocess); err != nil {
+			return xerrors.Errorf("前処理のパラメータの保存に失敗しました: %w", err)
+		}
 	}
 
 	var wg sync.WaitGroup
@@ -341,8 +726,10 @@ func Generate(opts *GenerateOptions) error {
 
 	wg.Add(1)
 	noteCenter := int(a3Note)
-	notesDelay := -(opts.F0Delay + baseF0Delay)
+	notesDelay := -(opts.F0Delay + profile.BaseF0Delay)
 	notes := []float64{}
+	rawF0 := []float64{}
+	genValue := -1
 	f0done := false
 	go func() {
 		defer wg.Done()
@@ -351,37 +738,58 @@ func Generate(opts *GenerateOptions) error {
 		}()
 		var err error
 		f0file := objPrefix + ".f0"
-		if isNewer(f0file, convertedWavFile) {
+		rawF0File := objPrefix + ".f0raw"
+		if isNewer(f0file, convertedWavFile) && isNewer(rawF0File, convertedWavFile) {
 			log.Printf("info: 推定済み基本周波数のキャッシュを使用します: %s", f0file)
 			notes, err = loadF0Note(f0file)
+			if err == nil {
+				rawF0, err = loadF0Note(rawF0File)
+			}
 		} else {
-			notes, err = wavToF0Note(convertedWavFile, f0file, f0FramePeriod)
+			notes, rawF0, err = wavToF0Note(convertedWavFile, f0file, rawF0File, f0FramePeriod)
 		}
 		if err != nil {
 			errch <- xerrors.Errorf("基本周波数の推定に失敗しました: %w", err)
 			return
 		}
-		noteMin := 128.0
-		noteMax := .0
+		if !opts.F0Cleanup.isEmpty() {
+			log.Print("info: 基本周波数を補正中...")
+			notes, rawF0 = cleanupF0(rawF0, &opts.F0Cleanup, f0FramePeriod)
+		}
+		if opts.GENMode != "" {
+			log.Print("info: スペクトル包絡からGENを推定中...")
+			ref, err := referenceCentroid(opts.GENReference, singer, objPrefix)
+			if err != nil {
+				errch <- xerrors.Errorf("GENの基準値の推定に失敗しました: %w", err)
+				return
+			}
+			c, err := estimateFormantCentroid(convertedWavFile, noteToFreq(notes), f0FramePeriod)
+			if err != nil {
+				errch <- xerrors.Errorf("GENの推定に失敗しました: %w", err)
+				return
+			}
+			genValue = formantShiftToGEN(c, ref)
+			log.Printf("info: 推定したGEN: %d", genValue)
+		}
 		noteOffset := float64(opts.Transpose) / 100.0
 		for i := range notes {
 			notes[i] += noteOffset
-			note := notes[i]
-			if note < noteMin {
-				noteMin = note
-			}
-			if noteMax < note {
-				noteMax = note
-			}
 		}
-		noteCenter = int(math.Round((noteMax + noteMin) / 2.0))
-
-		log.Print("info: 基本周波数の変動をフィルタリング中...")
-		notes = resample(notes, resampleRate)
-		if opts.F0LPFCutoff != "" {
-			notes = convolve(notes, firLPF[opts.F0LPFCutoff])
-			notesDelay += float64(len(firLPF[opts.F0LPFCutoff])) / 2.0 * notesFramePeriod
+		stats, ok := voicedPitchStats(notes, rawF0)
+		if !ok {
+			log.Print("warn: 基本周波数が推定されたフレームがありません")
+			return
+		}
+		if opts.TransposeTo != "" {
+			shift := autoTranspose(opts, singer, stats.median)
+			for i := range notes {
+				notes[i] += float64(shift)
+			}
+			stats.low += float64(shift)
+			stats.median += float64(shift)
+			stats.high += float64(shift)
 		}
+		noteCenter = int(math.Round((stats.low + stats.high) / 2.0))
 	}()
 
 	if !parallel {
@@ -390,38 +798,17 @@ func Generate(opts *GenerateOptions) error {
 
 	wg.Add(1)
 	var result *julius.Result
+	var chunks []chunk
 	go func() {
 		defer wg.Done()
-		lastSec := -1
-		julius.OnProgress = func(progress, total float64) {
-			sec := int(progress/10.0) * 10
-			if lastSec != sec {
-				log.Printf("info: 進捗: %d / %d 秒", sec, int(math.Ceil(total)))
-				lastSec = sec
-			}
-		}
 		var err error
-		if isEmpty(opts.TextFile) || opts.Redictate {
-			result, err = julius.Dictate(convertedWavFile, opts.DictationModel)
-			if err != nil {
-				errch <- xerrors.Errorf("発話内容の推定に失敗しました: %w", err)
-				return
-			}
-			b := []byte(result.DictationString())
-			if len(b) == 0 {
-				errch <- xerrors.Errorf("音声ファイル中に認識可能な発話がありませんでした")
-				return
-			}
-			if err := ioutil.WriteFile(opts.TextFile, b, 0644); err != nil {
-				errch <- xerrors.Errorf("推定した発話内容の保存に失敗しました: %w", err)
-				return
-			}
+		if .0 < opts.ChunkLength {
+			result, chunks, err = recognizeChunks(opts, dict, convertedWavFile, textFile, objPrefix)
 		} else {
-			log.Print("info: 発話内容をテキストファイルから読み込みます")
+			result, err = recognize(opts, dict, juliusWavFile, textFile, objPrefix)
 		}
-		result, err = julius.Segmentate(convertedWavFile, opts.TextFile, objPrefix)
 		if err != nil {
-			errch <- xerrors.Errorf("発音タイミングの推定に失敗しました: %w", err)
+			errch <- err
 			return
 		}
 		if !f0done {
@@ -434,28 +821,84 @@ func Generate(opts *GenerateOptions) error {
 		return <-errch
 	}
 
+	boundaries := []float64{}
+	for _, c := range chunks {
+		boundaries = append(boundaries, c.beginTime(juliusSampleRate))
+	}
+
+	vibratos := map[int]*vibrato{}
+	if opts.Vibrato {
+		log.Print("info: ビブラートを検出中...")
+		for i, seg := range result.Segments {
+			v, _ := julius.SplitLong(seg.Unit)
+			if _, ok := julius.Vowels[v]; !ok {
+				continue
+			}
+			begin := int(math.Round((seg.BeginTime + notesDelay) / f0FramePeriod))
+			end := int(math.Round((seg.EndTime + notesDelay) / f0FramePeriod))
+			if v := detectVibrato(notes, begin, end, f0FramePeriod); v != nil {
+				vibratos[i] = v
+			}
+		}
+		log.Printf("info: 検出したビブラート: %d 箇所", len(vibratos))
+	}
+
+	devoiced := map[int]bool{}
+	if opts.Devoice != "" {
+		devoiced = detectDevoiced(result.Segments, rawF0, f0FramePeriod)
+		log.Printf("info: 検出した無声化母音: %d 箇所", len(devoiced))
+	}
+
+	log.Print("info: 基本周波数の変動をフィルタリング中...")
+	notes = resample(notes, resampleRate)
+	if .0 < opts.F0LPFCutoff {
+		ir, err := designLPF(opts.F0LPFCutoff, 1.0/notesFramePeriod, opts.F0LPFTaps, opts.F0LPFWindow)
+		if err != nil {
+			return xerrors.Errorf("LPFの設計に失敗しました: %w", err)
+		}
+		switch opts.F0Filter {
+		case "causal":
+			notes = convolve(notes, ir)
+			notesDelay += float64(len(ir)-1) / 2.0 * notesFramePeriod
+		default:
+			notes = filtfilt(notes, ir)
+		}
+	}
+
 	gen := generator{
 		noteCenter: noteCenter,
-		vsqx:       vsqx.New(opts.Singer, resolution, bpm),
+		vsqx:       vsq,
+		track:      track,
+		longVowel:  opts.LongVowel,
+		devoice:    opts.Devoice,
+		profile:    profile,
 	}
 	gen.reset()
+	splitParts(opts, track, result, boundaries, notesDelay)
+	if 0 <= genValue {
+		switch opts.GENMode {
+		case "param":
+			// ボイスパラメータは既定値（コントロールの 64）からの差で記述する
+			gen.vsqx.Voice(gen.track).VoiceParam.GEN = genValue - genCenter
+		case "ctrl":
+			for _, part := range gen.track.MusicalPart {
+				gen.track.AddMCtrl(part.BeginTick(), "GEN", genValue)
+			}
+		}
+	}
 
 	log.Print("info: VSQXを生成中...")
 	segsData := ""
-	for _, seg := range result.Segments {
+	for i, seg := range result.Segments {
 		segsData += fmt.Sprintf("%.7f %.7f %s\n", seg.BeginTime, seg.EndTime, seg.Unit)
 
-		unit := seg.Unit
-		long := strings.HasSuffix(unit, ":")
-		if long {
-			unit = unit[:len(unit)-1]
-		}
+		unit, long := julius.SplitLong(seg.Unit)
 		beginTime := seg.BeginTime + notesDelay
 		endTime := seg.EndTime + notesDelay
 
 		if unit == "q" {
 			gen.flush()
-			gen.vsqx.AddNote(
+			gen.track.AddNote(
 				64,
 				timeToTick(beginTime),
 				timeToTick(endTime),
@@ -469,7 +912,7 @@ func Generate(opts *GenerateOptions) error {
 		if s, ok := julius.SpecialsForVSQX[unit]; ok {
 			gen.flush()
 			if s != "" {
-				gen.vsqx.AddNote(
+				gen.track.AddNote(
 					64,
 					timeToTick(beginTime),
 					timeToTick(endTime),
@@ -495,7 +938,9 @@ func Generate(opts *GenerateOptions) error {
 				return xerrors.Errorf("テキストファイルの内容が不正です: %w", err)
 			}
 		}
-		gen.setVowel(beginTime, endTime, unit)
+		gen.setVowel(beginTime, endTime, unit, long)
+		gen.vowelVibrato = vibratos[i]
+		gen.vowelDevoiced = devoiced[i]
 		if err := gen.flush(); err != nil {
 			return xerrors.Errorf("テキストファイルの内容が不正です: %w", err)
 		}
@@ -507,13 +952,18 @@ func Generate(opts *GenerateOptions) error {
 	if err := ioutil.WriteFile(objPrefix+".seg", []byte(segsData), 0644); err != nil {
 		return xerrors.Errorf("セグメンテーションキャッシュファイルの保存に失敗しました: %w", err)
 	}
-
-	gen.feedPitchBends(notes, shiftBendTime)
-	if err := gen.save(opts.OutFile); err != nil {
-		return xerrors.Errorf("VSQXの保存に失敗しました: %w", err)
+	if opts.TextGridFile != "" {
+		if err := writeTextGrid(insertSuffix(opts.TextGridFile, suffix), result); err != nil {
+			return xerrors.Errorf("TextGridの保存に失敗しました: %w", err)
+		}
+	}
+	if opts.SRTFile != "" {
+		if err := writeSRT(insertSuffix(opts.SRTFile, suffix), result); err != nil {
+			return xerrors.Errorf("字幕ファイルの保存に失敗しました: %w", err)
+		}
 	}
 
-	log.Printf("info: 出力ノート数: %d", gen.vsqx.NoteCount())
-	log.Print("info: 完了")
+	gen.feedPitchBends(notes, profile.ShiftBendTime)
+	track.FitParts()
 	return nil
 }
@@ -0,0 +1,120 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/but80/talklistener/internal/julius"
+	"github.com/but80/talklistener/internal/vsqx"
+)
+
+const partNameMaxLength = 32 // パート名の最大文字数
+
+// PartSplitModes は、パートの分割方法として指定可能な値の一覧です。
+var PartSplitModes = []string{
+	"pause",
+	"line",
+}
+
+func isPause(unit string) bool {
+	return unit == "sp" || unit == "silB" || unit == "silE"
+}
+
+// pauseBoundaries は、minPause 秒以上続く無音区間の中央の時刻を返します。
+func pauseBoundaries(segs []julius.Segment, minPause float64) []float64 {
+	result := []float64{}
+	begin := -1.0
+	end := -1.0
+	flush := func() {
+		if .0 <= begin && minPause <= end-begin {
+			result = append(result, (begin+end)/2.0)
+		}
+		begin = -1.0
+	}
+	for _, seg := range segs {
+		if !isPause(seg.Unit) {
+			flush()
+			continue
+		}
+		if begin < .0 {
+			begin = seg.BeginTime
+		}
+		end = seg.EndTime
+	}
+	flush()
+	return result
+}
+
+// lineBoundaries は、テキストファイルの各行の発音が始まる時刻を返します（先頭行を除く）。
+func lineBoundaries(segs []julius.Segment) []float64 {
+	result := []float64{}
+	line := -1
+	for _, seg := range segs {
+		if seg.Line < 0 || seg.Line == line {
+			continue
+		}
+		if 0 <= line {
+			result = append(result, seg.BeginTime)
+		}
+		line = seg.Line
+	}
+	return result
+}
+
+// partName は、パートに含まれる行の内容からパート名を作ります。
+func partName(lines []string) string {
+	name := []rune(strings.Join(lines, " "))
+	if partNameMaxLength < len(name) {
+		name = append(name[:partNameMaxLength-1], '…')
+	}
+	return string(name)
+}
+
+// splitParts は、区間の境界や無音・行の位置でトラックのパートを分割し、各パートに名前を付けます。
+// boundaries は分割区間の境界（秒）、timeOffset はノートの配置時刻の補正値です。
+func splitParts(opts *GenerateOptions, track *vsqx.VSTrack, result *julius.Result, boundaries []float64, timeOffset float64) {
+	switch opts.PartSplit {
+	case "pause":
+		boundaries = append(boundaries, pauseBoundaries(result.Segments, opts.PartPause)...)
+	case "line":
+		boundaries = append(boundaries, lineBoundaries(result.Segments)...)
+	}
+	ticks := []int{}
+	for _, t := range boundaries {
+		if tick := timeToTick(t + timeOffset); 0 < tick {
+			ticks = append(ticks, tick)
+		}
+	}
+	sort.Ints(ticks)
+	last := 0
+	for _, tick := range ticks {
+		if tick != last {
+			track.SplitPart(tick)
+			last = tick
+		}
+	}
+
+	// 各パートに含まれる行の内容をパート名にする
+	lines := map[*vsqx.MusicalPart][]int{}
+	for _, seg := range result.Segments {
+		if seg.Line < 0 || len(result.Lines) <= seg.Line {
+			continue
+		}
+		part := track.PartAt(timeToTick(seg.BeginTime + timeOffset))
+		if n := len(lines[part]); n == 0 || lines[part][n-1] != seg.Line {
+			lines[part] = append(lines[part], seg.Line)
+		}
+	}
+	for i, part := range track.MusicalPart {
+		if 0 < len(lines[part]) {
+			texts := []string{}
+			for _, l := range lines[part] {
+				texts = append(texts, result.Lines[l])
+			}
+			part.PartName.Data = partName(texts)
+		} else if 1 < len(track.MusicalPart) {
+			part.PartName.Data = fmt.Sprintf("Part%d", i+1)
+		}
+	}
+}
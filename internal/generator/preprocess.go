@@ -0,0 +1,373 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/cmplx"
+	"reflect"
+	"sort"
+
+	"github.com/mjibson/go-dsp/fft"
+)
+
+const (
+	gateFrameTime      = .010 // ノイズゲートの音量を測る区間長（秒）
+	gateAttackTime     = .005
+	gateReleaseTime    = .050
+	gateRange          = -60.0 // ゲートが閉じたときの減衰量（dB）
+	defaultPeakLevel   = -1.0  // dBFS
+	defaultLUFSLevel   = -23.0 // LUFS
+	denoiseFrameSize   = 512
+	denoiseNoiseRatio  = .1  // 雑音とみなす、音量の小さいフレームの割合
+	denoiseSpectralMin = .02 // 減算後の振幅の下限（元の振幅に対する比）
+)
+
+// NormalizeModes は、音量の正規化方法として指定可能な値の一覧です。
+var NormalizeModes = []string{
+	"peak",
+	"lufs",
+}
+
+// PreprocessOptions は、フォーマット変換時に音声に施す前処理のパラメータです。
+// 各項目がゼロ値のとき、その処理は行いません。
+type PreprocessOptions struct {
+	HighPass       float64 `json:"highpass"`        // ハイパスフィルタのカットオフ周波数（Hz）
+	NoiseGate      float64 `json:"noise_gate"`      // ノイズゲートの閾値（dBFS）
+	Denoise        float64 `json:"denoise"`         // スペクトル減算の強さ
+	Normalize      string  `json:"normalize"`       // 音量の正規化方法
+	NormalizeLevel float64 `json:"normalize_level"` // 正規化後の音量（dBFS または LUFS）
+}
+
+func (pre *PreprocessOptions) isEmpty() bool {
+	return pre == nil || *pre == PreprocessOptions{}
+}
+
+func (pre *PreprocessOptions) validate() error {
+	if pre.Normalize != "" && !contains(NormalizeModes, pre.Normalize) {
+		return fmt.Errorf("音量の正規化方法 %s は定義されていません", pre.Normalize)
+	}
+	if .0 < pre.NoiseGate {
+		return fmt.Errorf("ノイズゲートの閾値は負の値（dBFS）で指定してください: %g", pre.NoiseGate)
+	}
+	if pre.Denoise < .0 {
+		return fmt.Errorf("ノイズ除去の強さは正の値で指定してください: %g", pre.Denoise)
+	}
+	return nil
+}
+
+// savePreprocessOptions は、前処理のパラメータをキャッシュと一緒に保存します。
+func savePreprocessOptions(filename string, pre *PreprocessOptions) error {
+	if pre == nil {
+		pre = &PreprocessOptions{}
+	}
+	b, err := json.MarshalIndent(pre, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, 0644)
+}
+
+// matchPreprocessOptions は、キャッシュ作成時の前処理のパラメータが pre と一致するかを返します。
+func matchPreprocessOptions(filename string, pre *PreprocessOptions) bool {
+	if pre == nil {
+		pre = &PreprocessOptions{}
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return pre.isEmpty()
+	}
+	var cached PreprocessOptions
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(&cached, pre)
+}
+
+// preprocess は、音声 x に前処理を施します。
+// 処理順は ハイパスフィルタ → ノイズ除去 → ノイズゲート → 音量の正規化 です。
+func preprocess(x []float64, fs int, pre *PreprocessOptions) []float64 {
+	if pre.isEmpty() {
+		return x
+	}
+	log.Print("info: 音声ファイルに前処理を適用中...")
+	if .0 < pre.HighPass {
+		x = highPass(x, fs, pre.HighPass)
+	}
+	if .0 < pre.Denoise {
+		x = spectralSubtraction(x, pre.Denoise)
+	}
+	if pre.NoiseGate < .0 {
+		x = noiseGate(x, fs, pre.NoiseGate)
+	}
+	switch pre.Normalize {
+	case "peak":
+		level := pre.NormalizeLevel
+		if level == .0 {
+			level = defaultPeakLevel
+		}
+		x = normalizePeak(x, level)
+	case "lufs":
+		level := pre.NormalizeLevel
+		if level == .0 {
+			level = defaultLUFSLevel
+		}
+		x = normalizeLoudness(x, fs, level)
+	}
+	return x
+}
+
+func dbToGain(db float64) float64 {
+	return math.Pow(10.0, db/20.0)
+}
+
+func gainToDB(g float64) float64 {
+	return 20.0 * math.Log10(g)
+}
+
+// biquad は、2次IIRフィルタの係数です（a0 で正規化済み）。
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+func (f biquad) apply(x []float64) []float64 {
+	result := make([]float64, len(x))
+	x1, x2, y1, y2 := .0, .0, .0, .0
+	for i, v := range x {
+		y := f.b0*v + f.b1*x1 + f.b2*x2 - f.a1*y1 - f.a2*y2
+		x2, x1 = x1, v
+		y2, y1 = y1, y
+		result[i] = y
+	}
+	return result
+}
+
+// highPassBiquad は、Audio EQ Cookbook のハイパスフィルタの係数を求めます。
+func highPassBiquad(cutoff, fs, q float64) biquad {
+	w := 2.0 * math.Pi * cutoff / fs
+	alpha := math.Sin(w) / (2.0 * q)
+	cos := math.Cos(w)
+	a0 := 1.0 + alpha
+	return biquad{
+		b0: (1.0 + cos) / 2.0 / a0,
+		b1: -(1.0 + cos) / a0,
+		b2: (1.0 + cos) / 2.0 / a0,
+		a1: -2.0 * cos / a0,
+		a2: (1.0 - alpha) / a0,
+	}
+}
+
+// highShelfBiquad は、Audio EQ Cookbook のハイシェルフフィルタの係数を求めます。
+func highShelfBiquad(freq, fs, gainDB, q float64) biquad {
+	a := math.Pow(10.0, gainDB/40.0)
+	w := 2.0 * math.Pi * freq / fs
+	alpha := math.Sin(w) / (2.0 * q)
+	cos := math.Cos(w)
+	sq := 2.0 * math.Sqrt(a) * alpha
+	a0 := (a + 1.0) - (a-1.0)*cos + sq
+	return biquad{
+		b0: a * ((a + 1.0) + (a-1.0)*cos + sq) / a0,
+		b1: -2.0 * a * ((a - 1.0) + (a+1.0)*cos) / a0,
+		b2: a * ((a + 1.0) + (a-1.0)*cos - sq) / a0,
+		a1: 2.0 * ((a - 1.0) - (a+1.0)*cos) / a0,
+		a2: ((a + 1.0) - (a-1.0)*cos - sq) / a0,
+	}
+}
+
+// highPass は、直流成分とハム等の低域雑音を2次バターワースフィルタで除去します。
+func highPass(x []float64, fs int, cutoff float64) []float64 {
+	return highPassBiquad(cutoff, float64(fs), math.Sqrt2/2.0).apply(x)
+}
+
+// noiseGate は、短時間の音量が閾値 threshold（dBFS）を下回る区間を減衰させます。
+func noiseGate(x []float64, fs int, threshold float64) []float64 {
+	frame := int(gateFrameTime * float64(fs))
+	if frame < 1 {
+		frame = 1
+	}
+	open := make([]bool, (len(x)+frame-1)/frame)
+	th := dbToGain(threshold)
+	for i := range open {
+		sum := .0
+		end := (i + 1) * frame
+		if len(x) < end {
+			end = len(x)
+		}
+		for _, v := range x[i*frame : end] {
+			sum += v * v
+		}
+		open[i] = th <= math.Sqrt(sum/float64(end-i*frame))
+	}
+
+	attack := math.Exp(-1.0 / (gateAttackTime * float64(fs)))
+	release := math.Exp(-1.0 / (gateReleaseTime * float64(fs)))
+	floor := dbToGain(gateRange)
+	result := make([]float64, len(x))
+	g := floor
+	for i, v := range x {
+		target, coef := floor, release
+		if open[i/frame] {
+			target, coef = 1.0, attack
+		}
+		g = target + (g-target)*coef
+		result[i] = v * g
+	}
+	return result
+}
+
+// spectralSubtraction は、音量の小さいフレームから推定した定常雑音のスペクトルを減算します。
+// strength は減算する雑音スペクトルの倍率です。
+// メモリ使用量を抑えるため、1回目の走査で雑音を推定し、2回目の走査でフレームごとに減算して重畳加算します。
+func spectralSubtraction(x []float64, strength float64) []float64 {
+	n := denoiseFrameSize
+	hop := n / 2
+	if len(x) < n {
+		return x
+	}
+	// 両端も2フレームで覆われるよう、前後を無音で埋めたものとして扱う
+	frames := (len(x)+hop-1)/hop + 1
+
+	win := make([]float64, n)
+	for i := range win {
+		win[i] = .5 - .5*math.Cos(2.0*math.Pi*float64(i)/float64(n)) // periodic Hann（50%重なりで和が1）
+	}
+	buf := make([]float64, n)
+	readFrame := func(f int) {
+		for i := range buf {
+			buf[i] = .0
+			if j := (f-1)*hop + i; 0 <= j && j < len(x) {
+				buf[i] = x[j] * win[i]
+			}
+		}
+	}
+
+	energy := make([]float64, frames)
+	for f := range energy {
+		readFrame(f)
+		for _, v := range buf {
+			energy[f] += v * v
+		}
+	}
+
+	// 音量の小さいフレームの振幅スペクトルの平均を雑音とみなす（無音で埋めた両端のフレームは除く）
+	order := make([]int, frames-2)
+	for i := range order {
+		order[i] = i + 1
+	}
+	sort.Slice(order, func(i, j int) bool { return energy[order[i]] < energy[order[j]] })
+	m := int(math.Ceil(float64(len(order)) * denoiseNoiseRatio))
+	noise := make([]float64, n)
+	for _, f := range order[:m] {
+		readFrame(f)
+		for k, c := range fft.FFTReal(buf) {
+			noise[k] += cmplx.Abs(c) / float64(m)
+		}
+	}
+
+	result := make([]float64, len(x))
+	for f := 0; f < frames; f++ {
+		readFrame(f)
+		spec := fft.FFTReal(buf)
+		for k, c := range spec {
+			mag := cmplx.Abs(c)
+			if mag == .0 {
+				continue
+			}
+			sub := math.Max(mag-strength*noise[k], denoiseSpectralMin*mag)
+			spec[k] = c * complex(sub/mag, 0)
+		}
+		for i, c := range fft.IFFT(spec) {
+			if j := (f-1)*hop + i; 0 <= j && j < len(x) {
+				result[j] += real(c)
+			}
+		}
+	}
+	return result
+}
+
+// normalizePeak は、最大振幅が level（dBFS）になるよう音量を調整します。
+func normalizePeak(x []float64, level float64) []float64 {
+	peak := .0
+	for _, v := range x {
+		peak = math.Max(peak, math.Abs(v))
+	}
+	if peak == .0 {
+		return x
+	}
+	log.Printf("debug: peak = %.2f dBFS", gainToDB(peak))
+	return applyGain(x, dbToGain(level)/peak)
+}
+
+// integratedLoudness は、ITU-R BS.1770 に従い、モノラル音声のラウドネス（LUFS）を求めます。
+func integratedLoudness(x []float64, fs int) float64 {
+	// K特性フィルタ
+	y := highShelfBiquad(1681.974450955533, float64(fs), 3.999843853973347, .7071752369554196).apply(x)
+	y = highPassBiquad(38.13547087602444, float64(fs), .5003270373238773).apply(y)
+
+	block := int(.4 * float64(fs))
+	step := block / 4
+	powers := []float64{}
+	for i := 0; i+block <= len(y); i += step {
+		sum := .0
+		for _, v := range y[i : i+block] {
+			sum += v * v
+		}
+		powers = append(powers, sum/float64(block))
+	}
+	loudness := func(p float64) float64 {
+		return -.691 + 10.0*math.Log10(p)
+	}
+	gatedMean := func(threshold float64) float64 {
+		sum, n := .0, 0
+		for _, p := range powers {
+			if .0 < p && threshold < loudness(p) {
+				sum += p
+				n++
+			}
+		}
+		if n == 0 {
+			return .0
+		}
+		return sum / float64(n)
+	}
+	p := gatedMean(-70.0)
+	if p == .0 {
+		return math.Inf(-1)
+	}
+	p = gatedMean(loudness(p) - 10.0)
+	if p == .0 {
+		return math.Inf(-1)
+	}
+	return loudness(p)
+}
+
+// normalizeLoudness は、ラウドネスが level（LUFS）になるよう音量を調整します。
+// 調整後にクリップする場合は、最大振幅が 0 dBFS になるまで音量を下げます。
+func normalizeLoudness(x []float64, fs int, level float64) []float64 {
+	l := integratedLoudness(x, fs)
+	if math.IsInf(l, -1) {
+		log.Print("warn: 音声が小さすぎるため、ラウドネスを測定できません")
+		return x
+	}
+	log.Printf("debug: loudness = %.2f LUFS", l)
+	g := dbToGain(level - l)
+	peak := .0
+	for _, v := range x {
+		peak = math.Max(peak, math.Abs(v)*g)
+	}
+	if 1.0 < peak {
+		log.Printf("warn: ラウドネスの正規化でクリップするため、音量を %.2f dB 下げます", gainToDB(peak))
+		g /= peak
+	}
+	return applyGain(x, g)
+}
+
+func applyGain(x []float64, g float64) []float64 {
+	result := make([]float64, len(x))
+	for i, v := range x {
+		result[i] = v * g
+	}
+	return result
+}
@@ -0,0 +1,147 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+
+	"github.com/but80/talklistener/internal/vsqx"
+	"golang.org/x/xerrors"
+)
+
+// Profile は、ノートの生成規則を調整するパラメータです。
+// JSONファイルでは、変更したい項目のみを記述します（省略した項目は既定値になります）。
+type Profile struct {
+	ExtendNoteTime       float64            `json:"extendNoteTime"` // ノートの終端を母音の終端から伸ばす時間（秒）
+	BaseF0Delay          float64            `json:"baseF0Delay"`    // 発音タイミングに対する基本周波数の変動の遅れ（秒）
+	ShiftBendTime        float64            `json:"shiftBendTime"`  // ピッチベンドをずらす時間（秒）
+	DurationRatio        float64            `json:"durationRatio"`  // 子音長に対するベロシティの変化の大きさ
+	VelocityBase         float64            `json:"velocityBase"`   // 子音長が velocityBaseDuration 秒のときのベロシティ
+	VelocityBaseDuration float64            `json:"velocityBaseDuration"`
+	VelocityScale        float64            `json:"velocityScale"` // 子音長の常用対数に対するベロシティの変化量
+	NoteStyle            map[string]int     `json:"noteStyle"`     // ノートのスタイル（accent, opening 等）の既定値を上書きします
+	PreUtterance         map[string]float64 `json:"preUtterance"`  // 子音の種類（plosive 等）ごとにノートの開始位置を早める時間（秒）
+}
+
+// defaultProfile は、従来の固定値と同じ規則です。
+var defaultProfile = Profile{
+	ExtendNoteTime:       0.025,
+	BaseF0Delay:          0.035,
+	ShiftBendTime:        0.0,
+	DurationRatio:        .5,
+	VelocityBase:         16.0,
+	VelocityBaseDuration: math.Pow(10.0, -0.622511616623867),
+	VelocityScale:        160.8972722,
+}
+
+// profilePresets は、シンガーごとのプリセットです（JSONファイルと同じ形式）。
+// いずれも実測に基づかない暫定値のため、--profile でプリセット名を指定した場合にのみ使用します。
+// Pull Request募集中
+var profilePresets = map[string]string{
+	"KAITO_V3_Soft":    `{"noteStyle": {"accent": 35}}`,
+	"KAITO_V3_Whisper": `{"noteStyle": {"accent": 35, "opening": 100}}`,
+	"VY1V3":            `{"baseF0Delay": 0.045}`,
+	"VY2V3":            `{"baseF0Delay": 0.045}`,
+	"VY2V3_falsetto":   `{"baseF0Delay": 0.045, "noteStyle": {"opening": 110}}`,
+}
+
+// ProfilePresets は、プリセットが用意されているシンガーの一覧を返します。
+func ProfilePresets() []string {
+	result := []string{}
+	for name := range profilePresets {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func parseProfile(data []byte) (*Profile, error) {
+	profile := defaultProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, err
+	}
+	if profile.VelocityBaseDuration <= .0 {
+		return nil, fmt.Errorf("velocityBaseDuration には正の値を指定してください")
+	}
+	for id := range profile.NoteStyle {
+		if !vsqx.IsNoteStyle(id) {
+			return nil, fmt.Errorf("ノートのスタイル %s は定義されていません", id)
+		}
+	}
+	for class := range profile.PreUtterance {
+		if !vsqx.IsConsonantClass(class) {
+			return nil, fmt.Errorf("子音の種類 %s は定義されていません", class)
+		}
+	}
+	return &profile, nil
+}
+
+// loadProfile は、ノートの生成規則を読み込みます。
+// name にはプリセット名（シンガー名）またはJSONファイル名を指定します。省略した場合は既定の規則を返します。
+func loadProfile(name string) (*Profile, error) {
+	if name == "" {
+		profile := defaultProfile
+		return &profile, nil
+	}
+	if preset, ok := profilePresets[name]; ok {
+		profile, err := parseProfile([]byte(preset))
+		if err != nil {
+			return nil, xerrors.Errorf("プリセット %s: %w", name, err)
+		}
+		return profile, nil
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := parseProfile(data)
+	if err != nil {
+		return nil, xerrors.Errorf("%s: %w", name, err)
+	}
+	return profile, nil
+}
+
+// hasProfilePreset は、シンガー singer のプリセットが用意されているかどうかを返します。
+func hasProfilePreset(singer string) bool {
+	_, ok := profilePresets[singer]
+	return ok
+}
+
+// velocity は、子音長 dur（秒）からノートのベロシティを求めます。
+func (profile *Profile) velocity(dur float64) int {
+	velocity := int(math.Round(profile.VelocityBase - math.Log10(dur/profile.VelocityBaseDuration)*profile.VelocityScale*profile.DurationRatio))
+	if velocity < 1 {
+		velocity = 1
+	} else if 127 < velocity {
+		velocity = 127
+	}
+	return velocity
+}
+
+// mergeSingerPreUtterance は、プロファイルで指定されていない子音の種類について、シンガー singer の値を使用します。
+func (profile *Profile) mergeSingerPreUtterance(singer string) {
+	merged := vsqx.SingerPreUtterance(singer)
+	for class, t := range profile.PreUtterance {
+		merged[class] = t
+	}
+	profile.PreUtterance = merged
+}
+
+// preUtterance は、子音の種類 class に対して、ノートの開始位置を早める時間（秒）を返します。
+func (profile *Profile) preUtterance(class string) float64 {
+	return profile.PreUtterance[class]
+}
+
+// apply は、トラックのノートのスタイルの既定値を設定します。
+func (profile *Profile) apply(track *vsqx.VSTrack) {
+	ids := []string{}
+	for id := range profile.NoteStyle {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		track.SetNoteStyle(id, profile.NoteStyle[id])
+	}
+}
@@ -0,0 +1,85 @@
+package generator
+
+import (
+	"math"
+)
+
+const (
+	resamplerZeroCrossings = 16   // 窓付きsinc関数の片側の零交差数
+	resamplerRolloff       = .945 // ナイキスト周波数に対する通過域の割合
+	resamplerKaiserBeta    = 8.0
+)
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// besselI0 は、第1種変形ベッセル関数 I0 を級数展開により求めます。
+func besselI0(x float64) float64 {
+	sum := 1.0
+	term := 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / 2.0 / float64(k)) * (x / 2.0 / float64(k))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
+
+// resampleAudio は、サンプリング周波数 from の音声 x を、窓付きsinc関数を用いたポリフェーズフィルタにより
+// サンプリング周波数 to に変換します。ダウンサンプリング時はエイリアシングを防ぐため通過域を狭めます。
+func resampleAudio(x []float64, from, to int) []float64 {
+	if from == to || len(x) == 0 {
+		return append([]float64{}, x...)
+	}
+	g := gcd(from, to)
+	up := to / g
+	down := from / g
+
+	// カットオフ周波数（入力のナイキスト周波数に対する比）
+	cutoff := resamplerRolloff
+	if to < from {
+		cutoff *= float64(to) / float64(from)
+	}
+	halfWidth := float64(resamplerZeroCrossings) / cutoff // 入力サンプル単位
+	width := int(math.Ceil(halfWidth))
+	norm := besselI0(resamplerKaiserBeta)
+
+	// 位相ごとのフィルタ係数: table[p][j] = h(p/up - (j-width+1))
+	taps := width * 2
+	table := make([][]float64, up)
+	for p := range table {
+		table[p] = make([]float64, taps)
+		for j := range table[p] {
+			t := float64(p)/float64(up) - float64(j-width+1)
+			if halfWidth < math.Abs(t) {
+				continue
+			}
+			r := t / halfWidth
+			w := besselI0(resamplerKaiserBeta*math.Sqrt(1.0-r*r)) / norm
+			table[p][j] = cutoff * sinc(cutoff*t) * w
+		}
+	}
+
+	n := int(math.Round(float64(len(x)) * float64(to) / float64(from)))
+	result := make([]float64, n)
+	for k := range result {
+		pos := k * down
+		n0 := pos / up
+		h := table[pos%up]
+		sum := .0
+		for j, c := range h {
+			i := n0 + j - width + 1
+			if 0 <= i && i < len(x) {
+				sum += x[i] * c
+			}
+		}
+		result[k] = sum
+	}
+	return result
+}
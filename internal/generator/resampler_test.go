@@ -0,0 +1,112 @@
+package generator
+
+import (
+	"math"
+	"testing"
+)
+
+// toneResponse は、周波数 freq（Hz）, サンプリング周波数 from の正弦波を to に変換し、
+// 出力に含まれる同じ周波数の成分の振幅と、それ以外の成分の実効値を返します。
+// 両端の過渡応答を避けるため、中央部分のみを評価します。
+func toneResponse(freq float64, from, to int) (float64, float64) {
+	x := make([]float64, from/2)
+	for i := range x {
+		x[i] = math.Sin(2.0 * math.Pi * freq * float64(i) / float64(from))
+	}
+	y := resampleAudio(x, from, to)
+	offset := len(y) / 4
+	y = y[offset : len(y)-offset]
+
+	// 最小二乗法で正弦波を当てはめる
+	ss, sc, cc, ys, yc := .0, .0, .0, .0, .0
+	for i, v := range y {
+		ph := 2.0 * math.Pi * freq * float64(offset+i) / float64(to)
+		s, c := math.Sin(ph), math.Cos(ph)
+		ss += s * s
+		sc += s * c
+		cc += c * c
+		ys += v * s
+		yc += v * c
+	}
+	det := ss*cc - sc*sc
+	a := (ys*cc - yc*sc) / det
+	b := (yc*ss - ys*sc) / det
+	residual := .0
+	for i, v := range y {
+		ph := 2.0 * math.Pi * freq * float64(offset+i) / float64(to)
+		d := v - a*math.Sin(ph) - b*math.Cos(ph)
+		residual += d * d
+	}
+	return math.Hypot(a, b), math.Sqrt(residual / float64(len(y)))
+}
+
+func db(x float64) float64 {
+	return 20.0 * math.Log10(x)
+}
+
+func TestResampleAudioSweep(t *testing.T) {
+	const to = juliusSampleRate
+	for _, from := range []int{44100, 48000, 8000} {
+		nyq := float64(from) / 2.0
+		if float64(to)/2.0 < nyq {
+			nyq = float64(to) / 2.0
+		}
+		for freq := 50.0; freq < float64(from)/2.0; freq *= 1.25 {
+			gain, residual := toneResponse(freq, from, to)
+			switch {
+			case freq <= nyq*.8:
+				// 通過域: 振幅が平坦で、エイリアシングやイメージが含まれないこと
+				if .1 < math.Abs(db(gain)) {
+					t.Errorf("%d Hz -> %d Hz: passband gain at %.0f Hz = %.2f dB", from, to, freq, db(gain))
+				}
+				if -60.0 < db(residual) {
+					t.Errorf("%d Hz -> %d Hz: residual at %.0f Hz = %.1f dB", from, to, freq, db(residual))
+				}
+			case nyq*1.1 <= freq:
+				// 阻止域: 変換後のナイキスト周波数を超える成分が除去されること
+				rms := math.Hypot(gain/math.Sqrt2, residual)
+				if -60.0 < db(rms) {
+					t.Errorf("%d Hz -> %d Hz: stopband level at %.0f Hz = %.1f dB", from, to, freq, db(rms))
+				}
+			}
+		}
+	}
+}
+
+func TestResampleAudioLength(t *testing.T) {
+	tests := []struct {
+		n, from, to int
+	}{
+		{n: 1, from: 44100, to: 16000},
+		{n: 2, from: 44100, to: 16000},
+		{n: 3, from: 48000, to: 16000},
+		{n: 1, from: 8000, to: 16000},
+		{n: 2, from: 8000, to: 16000},
+		{n: 44101, from: 44100, to: 16000},
+		{n: 48000, from: 48000, to: 16000},
+		{n: 8001, from: 8000, to: 16000},
+	}
+	for _, tt := range tests {
+		x := make([]float64, tt.n)
+		for i := range x {
+			x[i] = 1.0
+		}
+		// 末尾のサンプルを越えて入力を参照しないこと（範囲外の参照はパニックになる）
+		y := resampleAudio(x, tt.from, tt.to)
+		want := int(math.Round(float64(tt.n) * float64(tt.to) / float64(tt.from)))
+		if len(y) != want {
+			t.Errorf("n=%d %d Hz -> %d Hz: len = %d, want %d", tt.n, tt.from, tt.to, len(y), want)
+		}
+		// 十分長い直流信号は、末尾を含めて振幅が保たれること（末尾は片側のみの畳み込みとなるため半分程度）
+		if 1000 < tt.n {
+			mid := y[len(y)/2]
+			if 1e-3 < math.Abs(mid-1.0) {
+				t.Errorf("n=%d %d Hz -> %d Hz: dc gain = %g", tt.n, tt.from, tt.to, mid)
+			}
+			last := y[len(y)-1]
+			if last < .4 || 1.1 < last {
+				t.Errorf("n=%d %d Hz -> %d Hz: last sample = %g", tt.n, tt.from, tt.to, last)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package generator
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/but80/talklistener/internal/vsqx"
+)
+
+const (
+	pitchPercentileLow  = .05 // 音高の範囲の下限とみなすパーセンタイル
+	pitchPercentileHigh = .95 // 音高の範囲の上限とみなすパーセンタイル
+)
+
+// TransposeToSinger は、移調先としてシンガーの推奨音域を指定する値です。
+const TransposeToSinger = "singer"
+
+var noteNameRx = regexp.MustCompile(`^([A-Ga-g])([#b]?)(-?\d+)$`)
+
+var noteNameOffsets = map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}
+
+var noteNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// parseNoteName は、音名（C3 = 60）またはノート番号を解釈します。
+func parseNoteName(s string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 || 127 < n {
+			return 0, fmt.Errorf("ノート番号 %d は範囲外です", n)
+		}
+		return n, nil
+	}
+	m := noteNameRx.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("%s は音名として解釈できません", s)
+	}
+	octave, _ := strconv.Atoi(m[3])
+	n := noteNameOffsets[strings.ToUpper(m[1])] + (octave+2)*12
+	switch m[2] {
+	case "#":
+		n++
+	case "b":
+		n--
+	}
+	if n < 0 || 127 < n {
+		return 0, fmt.Errorf("音名 %s は範囲外です", s)
+	}
+	return n, nil
+}
+
+// noteName は、ノート番号を音名（C3 = 60）に変換します。
+func noteName(note int) string {
+	return fmt.Sprintf("%s%d", noteNames[(note%12+12)%12], int(math.Floor(float64(note)/12.0))-2)
+}
+
+// percentile は、ソート済みの sorted の p（0〜1）パーセンタイルを線形補間で求めます。
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	x := p * float64(len(sorted)-1)
+	i := int(math.Floor(x))
+	if len(sorted)-1 <= i {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (sorted[i+1]-sorted[i])*(x-float64(i))
+}
+
+// pitchStats は、基本周波数が推定されたフレームの音高の分布です。
+type pitchStats struct {
+	low    float64
+	median float64
+	high   float64
+}
+
+// voicedPitchStats は、raw（無声区間を補間していない基本周波数）が推定されたフレームについて、
+// notes の音高の分布をパーセンタイルで求めます。外れ値やオクターブエラーの影響を受けにくくするため、
+// 最小値・最大値の代わりに pitchPercentileLow, pitchPercentileHigh パーセンタイルを範囲とします。
+// 該当するフレームがない場合は false を返します。
+func voicedPitchStats(notes, raw []float64) (pitchStats, bool) {
+	voiced := []float64{}
+	for i, note := range notes {
+		if i < len(raw) && minFreq <= raw[i] {
+			voiced = append(voiced, note)
+		}
+	}
+	if len(voiced) == 0 {
+		return pitchStats{}, false
+	}
+	sort.Float64s(voiced)
+	return pitchStats{
+		low:    percentile(voiced, pitchPercentileLow),
+		median: percentile(voiced, .5),
+		high:   percentile(voiced, pitchPercentileHigh),
+	}, true
+}
+
+// transposeOffset は、音高の中央値 median を target に合わせるための移調量（半音）を求めます。
+// octave が true の場合は、オクターブ単位で移調します。
+func transposeOffset(median, target float64, octave bool) int {
+	if octave {
+		return 12 * int(math.Round((target-median)/12.0))
+	}
+	return int(math.Round(target - median))
+}
+
+// autoTranspose は、話者の音高の中央値 median を opts.TransposeTo で指定した音高に合わせるための移調量（半音）を求め、報告します。
+// シンガーの推奨音域を指定した場合は、中央値が推奨音域を外れるときのみ推奨音域の中央に合わせます。
+func autoTranspose(opts *GenerateOptions, singer string, median float64) int {
+	var target int
+	if opts.TransposeTo == TransposeToSinger {
+		r, ok := vsqx.SingerRange(singer)
+		if !ok {
+			log.Printf("warn: シンガー %s の推奨音域が定義されていないため、移調しません", singer)
+			return 0
+		}
+		if r.Contains(int(math.Round(median))) {
+			log.Printf("info: 話者の音高の中央値 %s はシンガー %s の推奨音域 %s〜%s に含まれるため、移調しません", noteName(int(math.Round(median))), singer, noteName(r.Low), noteName(r.High))
+			return 0
+		}
+		target = r.Center()
+	} else {
+		target, _ = parseNoteName(opts.TransposeTo)
+	}
+	shift := transposeOffset(median, float64(target), opts.TransposeOctave)
+	log.Printf("info: 話者の音高の中央値 %s を %s に合わせて %+d 半音移調します", noteName(int(math.Round(median))), noteName(target), shift)
+	return shift
+}
@@ -0,0 +1,147 @@
+package generator
+
+import (
+	"math"
+)
+
+const (
+	vibratoMinDuration    = 0.3  // ビブラートを検出する持続音の最短長（秒）
+	vibratoSkipRatio      = 0.2  // 持続音の冒頭のうち、検出対象から除外する割合
+	vibratoMinRate        = 4.0  // Hz
+	vibratoMaxRate        = 8.0  // Hz
+	vibratoMinDepth       = 0.15 // 振幅の下限（半音）
+	vibratoMinCorrelation = 0.5
+	vibratoType           = 1
+	vibratoDepthScale     = 64.0 / 0.5 // vibDep 64 ≒ 振幅 ±0.5半音（おおよその対応）
+	vibratoRateScale      = 64.0 / 6.0 // vibRate 64 ≒ 6Hz（おおよその対応）
+)
+
+type vibrato struct {
+	length int     // ノート長に対するビブラート区間の割合（%）
+	rate   float64 // 周期（Hz）
+	depth  float64 // 振幅（半音）
+}
+
+func (v *vibrato) vsqxDepth() int {
+	return clampInt(int(math.Round(v.depth*vibratoDepthScale)), 0, 127)
+}
+
+func (v *vibrato) vsqxRate() int {
+	return clampInt(int(math.Round(v.rate*vibratoRateScale)), 0, 127)
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	} else if max < v {
+		return max
+	}
+	return v
+}
+
+// detrend は、最小二乗法で求めた直線成分を除いた系列を返します。
+func detrend(x []float64) []float64 {
+	n := float64(len(x))
+	sumT, sumX, sumTT, sumTX := .0, .0, .0, .0
+	for i, v := range x {
+		t := float64(i)
+		sumT += t
+		sumX += v
+		sumTT += t * t
+		sumTX += t * v
+	}
+	slope := .0
+	if d := n*sumTT - sumT*sumT; d != .0 {
+		slope = (n*sumTX - sumT*sumX) / d
+	}
+	intercept := (sumX - slope*sumT) / n
+	result := make([]float64, len(x))
+	for i, v := range x {
+		result[i] = v - (intercept + slope*float64(i))
+	}
+	return result
+}
+
+// detectVibrato は、notes[begin:end] に含まれる周期的な揺れを検出します。
+// 検出された場合は、その揺れを notes から取り除きます。
+func detectVibrato(notes []float64, begin, end int, framePeriod float64) *vibrato {
+	if begin < 0 {
+		begin = 0
+	}
+	if len(notes) < end {
+		end = len(notes)
+	}
+	if float64(end-begin)*framePeriod < vibratoMinDuration {
+		return nil
+	}
+	skip := int(float64(end-begin) * vibratoSkipRatio)
+	begin += skip
+	x := detrend(notes[begin:end])
+	n := len(x)
+
+	fs := 1.0 / framePeriod
+	minLag := int(math.Floor(fs / vibratoMaxRate))
+	maxLag := int(math.Ceil(fs / vibratoMinRate))
+	if n < maxLag*2 {
+		return nil
+	}
+	r := make([]float64, maxLag+2)
+	for lag := minLag - 1; lag <= maxLag+1; lag++ {
+		sxy, sxx, syy := .0, .0, .0
+		for i := 0; i+lag < n; i++ {
+			sxy += x[i] * x[i+lag]
+			sxx += x[i] * x[i]
+			syy += x[i+lag] * x[i+lag]
+		}
+		if .0 < sxx && .0 < syy {
+			r[lag] = sxy / math.Sqrt(sxx*syy)
+		}
+	}
+	bestLag := 0
+	for lag := minLag; lag <= maxLag; lag++ {
+		if bestLag == 0 || r[bestLag] < r[lag] {
+			bestLag = lag
+		}
+	}
+	if r[bestLag] < vibratoMinCorrelation {
+		return nil
+	}
+
+	// 自己相関のピーク位置を放物線補間して周期を求める
+	period := float64(bestLag)
+	if d := r[bestLag-1] - 2.0*r[bestLag] + r[bestLag+1]; d < .0 {
+		period += .5 * (r[bestLag-1] - r[bestLag+1]) / d
+	}
+
+	// 検出した周期の正弦波を当てはめる
+	w := 2.0 * math.Pi / period
+	a, b := .0, .0
+	for i, v := range x {
+		a += v * math.Sin(w*float64(i))
+		b += v * math.Cos(w*float64(i))
+	}
+	a *= 2.0 / float64(n)
+	b *= 2.0 / float64(n)
+	depth := math.Hypot(a, b)
+	if depth < vibratoMinDepth {
+		return nil
+	}
+
+	// 揺れを取り除く（両端は半周期かけてフェードさせる）
+	fade := int(period / 2.0)
+	for i := 0; i < n; i++ {
+		g := 1.0
+		if i < fade {
+			g = .5 - .5*math.Cos(math.Pi*float64(i)/float64(fade))
+		} else if n-fade <= i {
+			g = .5 - .5*math.Cos(math.Pi*float64(n-1-i)/float64(fade))
+		}
+		notes[begin+i] -= g * (a*math.Sin(w*float64(i)) + b*math.Cos(w*float64(i)))
+	}
+
+	return &vibrato{
+		length: int(math.Round(100.0 * (1.0 - vibratoSkipRatio))),
+		rate:   fs / period,
+		depth:  depth,
+	}
+}
@@ -0,0 +1,62 @@
+package generator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDetectVibrato(t *testing.T) {
+	const framePeriod = .005
+	tests := []struct {
+		name     string
+		duration float64 // 秒
+		rate     float64 // Hz
+		depth    float64 // 半音
+		slope    float64 // 半音/秒
+		detected bool
+	}{
+		{name: "flat", duration: 1.0},
+		{name: "too short", duration: .25, rate: 6.0, depth: .5},
+		{name: "6Hz", duration: 1.0, rate: 6.0, depth: .5, detected: true},
+		{name: "4.5Hz with drift", duration: 1.2, rate: 4.5, depth: .3, slope: 1.0, detected: true},
+		{name: "7.5Hz", duration: .8, rate: 7.5, depth: 1.0, detected: true},
+		{name: "too shallow", duration: 1.0, rate: 6.0, depth: .05},
+		{name: "too slow", duration: 1.0, rate: 2.0, depth: .5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := int(tt.duration / framePeriod)
+			notes := make([]float64, n)
+			for i := range notes {
+				tm := float64(i) * framePeriod
+				notes[i] = 60.0 + tt.slope*tm + tt.depth*math.Sin(2.0*math.Pi*tt.rate*tm)
+			}
+			v := detectVibrato(notes, 0, n, framePeriod)
+			if !tt.detected {
+				if v != nil {
+					t.Errorf("detectVibrato() = %+v, want nil", *v)
+				}
+				return
+			}
+			if v == nil {
+				t.Fatal("detectVibrato() = nil, want vibrato")
+			}
+			if math.Abs(v.rate-tt.rate) > .3 {
+				t.Errorf("rate = %.2f, want %.2f", v.rate, tt.rate)
+			}
+			if math.Abs(v.depth-tt.depth) > tt.depth*.15 {
+				t.Errorf("depth = %.3f, want %.3f", v.depth, tt.depth)
+			}
+			// フェード区間を除き、揺れが取り除かれていること
+			skip := int(float64(n) * vibratoSkipRatio)
+			fade := int(1.0 / tt.rate / framePeriod)
+			for i := skip + fade; i < n-fade; i++ {
+				want := 60.0 + tt.slope*float64(i)*framePeriod
+				if d := math.Abs(notes[i] - want); d > tt.depth*.25 {
+					t.Errorf("notes[%d] = %.3f, want %.3f", i, notes[i], want)
+					break
+				}
+			}
+		})
+	}
+}
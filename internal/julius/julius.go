@@ -20,6 +20,10 @@ static void _register_callbacks(Recog* recog, void* data) {
 	callback_add(recog, CALLBACK_RESULT, onResult, data);
 }
 
+static void _jlog_set_output_stderr() {
+	jlog_set_output(stderr);
+}
+
 static Sentence* _read_sentence_array(Sentence* p, int index) {
 	return p + index;
 }
@@ -35,6 +39,9 @@ static unsigned char _read_uchar_array(unsigned char* p, int index) {
 static HMM_Logical* _read_hmm_logical(HMM_Logical** p, int index) {
 	return p[index];
 }
+static char* _read_string_array(char** p, int index) {
+	return p[index];
+}
 static HMM_Logical** _read_hmm_logical_ptr(HMM_Logical*** p, int index) {
 	return p[index];
 }
@@ -43,7 +50,9 @@ import "C"
 import (
 	"fmt"
 	"log"
+	"math"
 	"strings"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -71,11 +80,30 @@ type Segment struct {
 	EndTime    float64
 	Unit       string
 	Score      float64
+	Line       int // テキストファイル中の行番号（0〜, 該当しない場合は -1）
+}
+
+// Shift は、セグメントの位置を offset 秒ずらします。
+// フレーム番号は、offset をフレーム周期の整数倍に丸めてずらします。
+func (seg *Segment) Shift(offset float64) {
+	frames := int(math.Round(offset / frameShiftSize))
+	seg.BeginFrame += frames
+	seg.EndFrame += frames
+	seg.BeginTime += offset
+	seg.EndTime += offset
+}
+
+// Word は、発話内容の推定結果の単語です。
+type Word struct {
+	Surface  string   // 表記
+	Phonemes []string // 読み（発音記号列）
 }
 
 type Result struct {
 	Dictation [][]string
+	Words     []Word
 	Segments  []Segment
+	Lines     []string // 発音タイミングの推定に用いたテキストファイルの各行
 	frame     int
 	totalSec  float64
 	completed bool
@@ -83,6 +111,10 @@ type Result struct {
 
 var OnProgress func(float64, float64)
 
+// runMutex は、認識処理を同時に1つだけ実行するためのロックです。
+// Julius の音声ファイル入力はグローバルな状態を持つため、複数の認識を並行して実行できません。
+var runMutex sync.Mutex
+
 func (result *Result) DictationString() string {
 	s := ""
 	for _, dic := range result.Dictation {
@@ -93,10 +125,16 @@ func (result *Result) DictationString() string {
 }
 
 func run(argv []string, wavfile string) (*Result, error) {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
 	if globalopt.Debug {
 		C.j_enable_debug_message()
 	}
-	if !globalopt.Verbose {
+	if globalopt.Verbose {
+		// 標準出力はVSQXの出力に使用することがあるため、ログは標準エラー出力に出す
+		C._jlog_set_output_stderr()
+	} else {
 		C.jlog_set_output(nil)
 	}
 
@@ -149,7 +187,9 @@ func onPass1Frame(recog *C.Recog, data unsafe.Pointer) {
 	samples := int(recog.speechlen)
 	rate := int(recog.jconf.input.sfreq)
 	totalSec := float64(samples) / float64(rate)
-	OnProgress(float64(result.frame)*frameShiftSize, totalSec)
+	if OnProgress != nil {
+		OnProgress(float64(result.frame)*frameShiftSize, totalSec)
+	}
 	result.frame++
 }
 
@@ -239,6 +279,10 @@ func (result *Result) update(proc *C.RecogProcess) {
 		for i := 0; i < seqnum; i++ {
 			w := int(sent.word[i])
 			wl := int(C._read_uchar_array(winfo.wlen, C.int(w)))
+			word := Word{}
+			if p := C._read_string_array(winfo.woutput, C.int(w)); p != nil {
+				word.Surface = C.GoString(p)
+			}
 			for j := 0; j < wl; j++ {
 				p := C._read_hmm_logical_ptr(winfo.wseq, C.int(w))
 				if p == nil {
@@ -250,7 +294,9 @@ func (result *Result) update(proc *C.RecogProcess) {
 				}
 				unit := C.GoString(ws.name)
 				dictation = append(dictation, centerName(unit))
+				word.Phonemes = append(word.Phonemes, centerName(unit))
 			}
+			result.Words = append(result.Words, word)
 		}
 		result.Dictation = append(result.Dictation, dictation)
 
@@ -277,6 +323,7 @@ func (result *Result) update(proc *C.RecogProcess) {
 						EndTime:    float64(end+1)*frameShiftSize + offsetAlign + frameSize,
 						Unit:       centerName(unit),
 						Score:      score,
+						Line:       -1,
 					}
 					result.Segments = append(result.Segments, seg)
 				}
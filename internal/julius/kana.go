@@ -302,17 +302,103 @@ var kanaToPhoneticTable = [][2]string{
 
 	//その他特別なルール
 	{"を", " o"},
-	{`\s*:(\s*:)+`, ":"},
 }
 
+// kanaTrie は、kanaToPhoneticTable の変換規則を最長一致で検索するためのトライ木です。
+type kanaTrie struct {
+	children map[rune]*kanaTrie
+	rule     int // 規則の優先順位（表中の位置）。規則の終端でない場合は -1
+}
+
+type kanaMatch struct {
+	rule int
+	n    int // 一致した文字数
+}
+
+func newKanaTrie(table [][2]string) *kanaTrie {
+	root := &kanaTrie{rule: -1}
+	for i, t := range table {
+		node := root
+		for _, r := range t[0] {
+			if node.children == nil {
+				node.children = map[rune]*kanaTrie{}
+			}
+			child, ok := node.children[r]
+			if !ok {
+				child = &kanaTrie{rule: -1}
+				node.children[r] = child
+			}
+			node = child
+		}
+		// 同じ表記の規則が重複する場合は先に定義されたものを優先する
+		if node.rule < 0 {
+			node.rule = i
+		}
+	}
+	return root
+}
+
+// matches は、runes[i:] の先頭に一致する規則を、長いものから順に返します。
+func (trie *kanaTrie) matches(runes []rune, i int) []kanaMatch {
+	result := []kanaMatch{}
+	node := trie
+	for j := i; j < len(runes); j++ {
+		child, ok := node.children[runes[j]]
+		if !ok {
+			break
+		}
+		node = child
+		if 0 <= node.rule {
+			result = append([]kanaMatch{{rule: node.rule, n: j - i + 1}}, result...)
+		}
+	}
+	return result
+}
+
+// match は、runes[i:] の先頭で適用する規則を返します。
+// 従来の表の先頭から順に置換する方式と同じ結果になるよう、一致範囲の途中から
+// より優先順位の高い規則が一致する場合は、その手前で終わる規則を選びます。
+func (trie *kanaTrie) match(runes []rune, i int) (kanaMatch, bool) {
+	for _, m := range trie.matches(runes, i) {
+		preceded := false
+		for k := i + 1; k < i+m.n && !preceded; k++ {
+			for _, o := range trie.matches(runes, k) {
+				if o.rule < m.rule {
+					preceded = true
+					break
+				}
+			}
+		}
+		if !preceded {
+			return m, true
+		}
+	}
+	return kanaMatch{}, false
+}
+
+var kanaToPhoneticTrie = newKanaTrie(kanaToPhoneticTable)
+
+// 連続する長音記号をまとめる
+var longVowelRx = regexp.MustCompile(`\s*:(\s*:)+`)
+
 func kanaToPhonetic(line string) string {
-	line = strings.TrimSpace(line)
-	for _, t := range kanaToPhoneticTable {
-		rx, _ := regexp.Compile(t[0])
-		line = rx.ReplaceAllString(line, t[1])
+	runes := []rune(strings.TrimSpace(line))
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		if m, ok := kanaToPhoneticTrie.match(runes, i); ok {
+			b.WriteString(kanaToPhoneticTable[m.rule][1])
+			i += m.n
+			continue
+		}
+		b.WriteRune(runes[i])
+		i++
 	}
-	line = strings.TrimSpace(line)
-	return line
+	return strings.TrimSpace(longVowelRx.ReplaceAllString(b.String(), ":"))
+}
+
+// KanaToPhonetic は、ひらがなの読みを音素列に変換します。
+func KanaToPhonetic(kana string) []string {
+	return strings.Fields(kanaToPhonetic(kana))
 }
 
 var Vowels = map[string]int{
@@ -378,7 +464,11 @@ var SpecialsForVSQX = map[string]string{
 	"N":    "ん",
 }
 
-func splitLong(vs string) (string, bool) {
+// LongVowelKana は、長音を表す文字です。
+const LongVowelKana = "ー"
+
+// SplitLong は、音素 vs から長音の記号 ":" を取り除き、長音であったかどうかを併せて返します。
+func SplitLong(vs string) (string, bool) {
 	if strings.HasSuffix(vs, ":") {
 		return vs[:len(vs)-1], true
 	}
@@ -386,7 +476,7 @@ func splitLong(vs string) (string, bool) {
 }
 
 func splitVowel(vs string) (vi int, long bool, ok bool) {
-	vs, long = splitLong(vs)
+	vs, long = SplitLong(vs)
 	if vi, ok = Vowels[vs]; ok {
 		return
 	}
@@ -401,7 +491,7 @@ func phoneticToKana(p []string) []string {
 		if ok {
 			result = append(result, Consonants[""].Kana[v])
 			if long {
-				result = append(result, "ー")
+				result = append(result, LongVowelKana)
 			}
 			continue
 		}
@@ -413,7 +503,7 @@ func phoneticToKana(p []string) []string {
 		if ok && 0 <= v && v < len(cons.Kana) {
 			result = append(result, cons.Kana[v])
 			if long {
-				result = append(result, "ー")
+				result = append(result, LongVowelKana)
 			}
 			i++
 			continue
@@ -442,3 +532,9 @@ func joinKana(k []string) string {
 	}
 	return joinKanaRx2.ReplaceAllString(strings.TrimSpace(result), " ")
 }
+
+// PhoneticToKana は、音素列をひらがなの読みに変換します（KanaToPhonetic の逆変換）。
+// 音響モデルに含まれる音素からなる列であれば、変換した読みを KanaToPhonetic で元の音素列に戻せます。
+func PhoneticToKana(phonemes []string) string {
+	return joinKana(phoneticToKana(phonemes))
+}
@@ -0,0 +1,99 @@
+package julius
+
+import (
+	"math/rand"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// regexKanaToPhonetic は、トライ木に置き換える前の、表の先頭から順に正規表現で置換する変換です。
+type regexKanaToPhonetic []*regexp.Regexp
+
+func newRegexKanaToPhonetic() regexKanaToPhonetic {
+	result := regexKanaToPhonetic{}
+	for _, t := range kanaToPhoneticTable {
+		result = append(result, regexp.MustCompile(t[0]))
+	}
+	return append(result, regexp.MustCompile(`\s*:(\s*:)+`))
+}
+
+func (rxs regexKanaToPhonetic) convert(line string) string {
+	line = strings.TrimSpace(line)
+	for i, rx := range rxs {
+		repl := ":"
+		if i < len(kanaToPhoneticTable) {
+			repl = kanaToPhoneticTable[i][1]
+		}
+		line = rx.ReplaceAllString(line, repl)
+	}
+	return strings.TrimSpace(line)
+}
+
+func TestKanaToPhonetic(t *testing.T) {
+	old := newRegexKanaToPhonetic()
+	check := func(kana string) {
+		t.Helper()
+		if got, want := kanaToPhonetic(kana), old.convert(kana); got != want {
+			t.Errorf("kanaToPhonetic(%q) = %q, want %q", kana, got, want)
+		}
+	}
+
+	// 表の全項目の単独、および全項目の組の変換
+	keys := []string{}
+	for _, t := range kanaToPhoneticTable {
+		keys = append(keys, t[0])
+	}
+	keys = append(keys, "ー", " ", "っ", "ん")
+	for _, a := range keys {
+		check(a)
+		for _, b := range keys {
+			check(a + b)
+		}
+	}
+
+	// 表の項目を無作為に連結した文字列の変換
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		var b strings.Builder
+		for n := rnd.Intn(8) + 1; 0 < n; n-- {
+			b.WriteString(keys[rnd.Intn(len(keys))])
+		}
+		check(b.String())
+	}
+
+	for _, s := range []string{"", "こんにちわ", "きょうわ いい てんきですね", "ゔぁいおりん", "すーぱーーまーけっと", "  あ  "} {
+		check(s)
+	}
+}
+
+func TestPhoneticToKana(t *testing.T) {
+	for c, cons := range Consonants {
+		for _, kana := range cons.Kana {
+			if kana == "" {
+				continue
+			}
+			for _, suffix := range []string{"", LongVowelKana, "っ", "ん"} {
+				phonemes := KanaToPhonetic(kana + suffix)
+				hmm := true
+				for _, p := range phonemes {
+					if !IsHMMPhone(p) {
+						hmm = false
+					}
+				}
+				got := PhoneticToKana(phonemes)
+				if want := joinKana(phoneticToKana(phonemes)); got != want {
+					t.Errorf("PhoneticToKana(%q) = %q, want %q", phonemes, got, want)
+				}
+				if !hmm {
+					continue
+				}
+				// 音響モデルに含まれる音素からなる列は、元の音素列に戻せること
+				if back := KanaToPhonetic(got); !reflect.DeepEqual(back, phonemes) {
+					t.Errorf("[%s] KanaToPhonetic(PhoneticToKana(%q)) = %q", c, phonemes, back)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package julius
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TranscriptError は、テキストファイル中の読みとして解釈できない箇所を表します。
+type TranscriptError struct {
+	Line   int // 行番号（1〜）
+	Column int // 行頭からの文字数（1〜）
+	Text   string
+}
+
+func (e *TranscriptError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%d 文字目の「%s」は読みとして解釈できません", e.Column, e.Text)
+	}
+	return fmt.Sprintf("%d 行 %d 文字目の「%s」は読みとして解釈できません", e.Line, e.Column, e.Text)
+}
+
+// TranscriptErrors は、テキストファイル中の読みとして解釈できない箇所の一覧です。
+type TranscriptErrors []*TranscriptError
+
+const maxReportedTranscriptErrors = 10
+
+func (errs TranscriptErrors) Error() string {
+	msgs := []string{}
+	for i, e := range errs {
+		if i == maxReportedTranscriptErrors {
+			msgs = append(msgs, fmt.Sprintf("ほか %d 箇所", len(errs)-i))
+			break
+		}
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// romajiTable は、ヘボン式・訓令式のローマ字とひらがなの対応表です。
+var romajiTable = map[string]string{
+	"a": "あ", "i": "い", "u": "う", "e": "え", "o": "お",
+	"ka": "か", "ki": "き", "ku": "く", "ke": "け", "ko": "こ",
+	"sa": "さ", "si": "し", "shi": "し", "su": "す", "se": "せ", "so": "そ",
+	"ta": "た", "ti": "ち", "chi": "ち", "tu": "つ", "tsu": "つ", "te": "て", "to": "と",
+	"na": "な", "ni": "に", "nu": "ぬ", "ne": "ね", "no": "の",
+	"ha": "は", "hi": "ひ", "hu": "ふ", "fu": "ふ", "he": "へ", "ho": "ほ",
+	"ma": "ま", "mi": "み", "mu": "む", "me": "め", "mo": "も",
+	"ya": "や", "yu": "ゆ", "ye": "いぇ", "yo": "よ",
+	"ra": "ら", "ri": "り", "ru": "る", "re": "れ", "ro": "ろ",
+	"wa": "わ", "wi": "うぃ", "we": "うぇ", "wo": "を",
+	"ga": "が", "gi": "ぎ", "gu": "ぐ", "ge": "げ", "go": "ご",
+	"za": "ざ", "zi": "じ", "ji": "じ", "zu": "ず", "ze": "ぜ", "zo": "ぞ",
+	"da": "だ", "di": "ぢ", "du": "づ", "de": "で", "do": "ど",
+	"ba": "ば", "bi": "び", "bu": "ぶ", "be": "べ", "bo": "ぼ",
+	"pa": "ぱ", "pi": "ぴ", "pu": "ぷ", "pe": "ぺ", "po": "ぽ",
+	"va": "う゛ぁ", "vi": "う゛ぃ", "vu": "う゛", "ve": "う゛ぇ", "vo": "う゛ぉ",
+	"fa": "ふぁ", "fi": "ふぃ", "fe": "ふぇ", "fo": "ふぉ",
+	"kya": "きゃ", "kyu": "きゅ", "kyo": "きょ",
+	"sha": "しゃ", "shu": "しゅ", "she": "しぇ", "sho": "しょ",
+	"sya": "しゃ", "syu": "しゅ", "syo": "しょ",
+	"cha": "ちゃ", "chu": "ちゅ", "che": "ちぇ", "cho": "ちょ",
+	"tya": "ちゃ", "tyu": "ちゅ", "tyo": "ちょ",
+	"nya": "にゃ", "nyu": "にゅ", "nyo": "にょ",
+	"hya": "ひゃ", "hyu": "ひゅ", "hyo": "ひょ",
+	"mya": "みゃ", "myu": "みゅ", "myo": "みょ",
+	"rya": "りゃ", "ryu": "りゅ", "ryo": "りょ",
+	"gya": "ぎゃ", "gyu": "ぎゅ", "gyo": "ぎょ",
+	"ja": "じゃ", "ju": "じゅ", "je": "じぇ", "jo": "じょ",
+	"zya": "じゃ", "zyu": "じゅ", "zyo": "じょ",
+	"jya": "じゃ", "jyu": "じゅ", "jyo": "じょ",
+	"bya": "びゃ", "byu": "びゅ", "byo": "びょ",
+	"pya": "ぴゃ", "pyu": "ぴゅ", "pyo": "ぴょ",
+	"thi": "てぃ", "dhi": "でぃ", "twu": "とぅ", "dwu": "どぅ",
+}
+
+// 長音記号付きの母音（ヘボン式のマクロン、訓令式のサーカムフレックス）
+var romajiLongVowels = map[rune]rune{
+	'ā': 'a', 'ī': 'i', 'ū': 'u', 'ē': 'e', 'ō': 'o',
+	'â': 'a', 'î': 'i', 'û': 'u', 'ê': 'e', 'ô': 'o',
+}
+
+// 読みの区切りとして空白と同様に扱う記号
+const transcriptSeparators = "、。，．,.！？!?「」『』（）()・…〜~"
+
+func isPhoneticSymbol(s string) bool {
+	if _, ok := Vowels[s]; ok {
+		return true
+	}
+	if _, ok := specials[s]; ok {
+		return true
+	}
+	if s == "" {
+		return false
+	}
+	_, ok := Consonants[s]
+	return ok
+}
+
+func isRomajiLetter(r rune) bool {
+	_, long := romajiLongVowels[unicode.ToLower(r)]
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '\'' || long
+}
+
+func isTranscriptKana(r rune) bool {
+	return 'ぁ' <= r && r <= 'ゔ' || r == 'ー' || r == '゛'
+}
+
+// normalizeTranscript は、カタカナ・ローマ字・全角英字を含む読みを、kanaToPhonetic が解釈できる
+// ひらがなと発音記号からなる文字列に変換します。解釈できない文字がある場合は *TranscriptError を返します。
+// dict を指定すると、ユーザー辞書に登録された表記を最長一致で読みに置換します。
+func normalizeTranscript(line string, dict *UserDictionary) (string, error) {
+	kana, _, err := normalizeTranscriptColumns(line, dict)
+	return kana, err
+}
+
+// normalizeTranscriptColumns は、normalizeTranscript と同様に読みを変換し、
+// 変換結果の各文字に対応する変換前の文字位置（0〜）を併せて返します。
+func normalizeTranscriptColumns(line string, dict *UserDictionary) (string, []int, error) {
+	runes := []rune(line)
+	for i, r := range runes {
+		if 'Ａ' <= r && r <= 'Ｚ' || 'ａ' <= r && r <= 'ｚ' {
+			runes[i] = r - 'Ａ' + 'A'
+		}
+	}
+	out := []rune{}
+	cols := []int{}
+	write := func(s string, col int) {
+		for _, r := range s {
+			out = append(out, r)
+			cols = append(cols, col)
+		}
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if reading, n := dict.lookup(runes, i); 0 < n {
+			kana, _ := normalizeTranscript(reading, nil)
+			write(" "+kana+" ", i)
+			i += n - 1
+			continue
+		}
+		switch {
+		case r == 'ゔ' || r == 'ヴ':
+			write("う゛", i)
+		case r == 'ヵ' || r == 'ゕ':
+			write("か", i)
+		case r == 'ヶ' || r == 'ゖ':
+			write("け", i)
+		case 'ァ' <= r && r <= 'ン':
+			write(string(r-'ァ'+'ぁ'), i)
+		case r == 'ｰ' || r == '－':
+			write("ー", i)
+		case r == 'ﾞ':
+			write("゛", i)
+		case isTranscriptKana(r):
+			write(string(r), i)
+		case unicode.IsSpace(r) || strings.ContainsRune(transcriptSeparators, r):
+			write(" ", i)
+		case isRomajiLetter(r):
+			j := i
+			for j < len(runes) && isRomajiLetter(runes[j]) {
+				j++
+			}
+			kana, err := romajiToKana(string(runes[i:j]))
+			if err != nil {
+				err.(*TranscriptError).Column += i
+				return "", nil, err
+			}
+			write(kana, i)
+			i = j - 1
+		default:
+			// 解釈できない文字が続く範囲をまとめて報告する
+			j := i + 1
+			for j < len(runes) && !isTranscriptKana(runes[j]) && !isRomajiLetter(runes[j]) &&
+				!unicode.IsSpace(runes[j]) && !strings.ContainsRune(transcriptSeparators, runes[j]) {
+				if _, n := dict.lookup(runes, j); 0 < n {
+					break
+				}
+				j++
+			}
+			return "", nil, &TranscriptError{Column: i + 1, Text: string(runes[i:j])}
+		}
+	}
+	return string(out), cols, nil
+}
+
+// romajiToKana は、ローマ字の単語をひらがなに変換します。
+// 単語全体が発音記号（子音のみの箇所の記述や sp 等）である場合は、前後に空白を付けてそのまま返します。
+func romajiToKana(word string) (string, error) {
+	if isPhoneticSymbol(word) {
+		return " " + word + " ", nil
+	}
+	runes := []rune(strings.ToLower(word))
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if v, ok := romajiLongVowels[r]; ok {
+			b.WriteString(romajiTable[string(v)] + "ー")
+			i++
+			continue
+		}
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		if r == 'n' && (next == 'n' || next == '\'') {
+			b.WriteRune('ん')
+			i++
+			if next == '\'' {
+				i++
+			}
+			continue
+		}
+		if r == 'n' && !strings.ContainsRune("aiueoy", next) {
+			if _, long := romajiLongVowels[next]; !long {
+				b.WriteRune('ん')
+				i++
+				continue
+			}
+		}
+		if r == 'm' && strings.ContainsRune("bpm", next) {
+			// ヘボン式では b, p, m の前の「ん」を m で表す
+			b.WriteRune('ん')
+			i++
+			continue
+		}
+		if r == next && !strings.ContainsRune("aiueo'", r) {
+			b.WriteRune('っ')
+			i++
+			continue
+		}
+		if r == 't' && next == 'c' {
+			// ヘボン式の「っち」(tchi)
+			b.WriteRune('っ')
+			i++
+			continue
+		}
+		matched := false
+		for l := 3; 1 <= l; l-- {
+			if len(runes) < i+l {
+				continue
+			}
+			s := string(runes[i : i+l])
+			if k, ok := romajiTable[s]; ok {
+				b.WriteString(k)
+				i += l
+				matched = true
+				break
+			}
+			// 末尾の母音が長音記号付きの場合
+			if v, ok := romajiLongVowels[runes[i+l-1]]; ok {
+				if k, ok := romajiTable[string(runes[i:i+l-1])+string(v)]; ok {
+					b.WriteString(k + "ー")
+					i += l
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			return "", &TranscriptError{Column: i + 1, Text: string([]rune(word)[i:])}
+		}
+	}
+	return b.String(), nil
+}
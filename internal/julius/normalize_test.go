@@ -0,0 +1,51 @@
+package julius
+
+import (
+	"testing"
+)
+
+func TestRomajiToKana(t *testing.T) {
+	tests := []struct {
+		word    string
+		want    string
+		wantErr int // エラーとなる列（0 はエラーなし）
+	}{
+		{word: "konnichiwa", want: "こんにちわ"},
+		{word: "Sushi", want: "すし"},
+		{word: "tsukue", want: "つくえ"},
+		{word: "shinbun", want: "しんぶん"},
+		{word: "shimbun", want: "しんぶん"},
+		{word: "kan'i", want: "かんい"},
+		{word: "kani", want: "かに"},
+		{word: "gakkou", want: "がっこう"},
+		{word: "matcha", want: "まっちゃ"},
+		{word: "kyouto", want: "きょうと"},
+		{word: "tōkyō", want: "とーきょー"},
+		{word: "Tôkyô", want: "とーきょー"},
+		{word: "fuji", want: "ふじ"},
+		{word: "hon", want: "ほん"},
+		{word: "sp", want: " sp "},
+		{word: "k", want: " k "},
+		{word: "xqz", wantErr: 1},
+		{word: "kaq", wantErr: 3},
+	}
+	for _, tt := range tests {
+		got, err := romajiToKana(tt.word)
+		if tt.wantErr != 0 {
+			te, ok := err.(*TranscriptError)
+			if !ok {
+				t.Errorf("romajiToKana(%q) error = %v, want TranscriptError", tt.word, err)
+			} else if te.Column != tt.wantErr {
+				t.Errorf("romajiToKana(%q) error column = %d, want %d", tt.word, te.Column, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("romajiToKana(%q) error = %v", tt.word, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("romajiToKana(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/but80/talklistener/internal/assets"
+	"golang.org/x/xerrors"
 )
 
 const (
@@ -16,47 +18,143 @@ const (
 	// hmmDefs = "/cmodules/segmentation-kit/models/hmmdefs_ptm_gid.binhmm" // triphone model
 )
 
-func wordsToDict(infile, outfile string) ([]string, error) {
-	words, err := loadWords(infile)
+func wordsToDict(infile, outfile string, dict *UserDictionary) ([]string, []string, error) {
+	words, lines, err := loadWords(infile, dict)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	file, err := os.OpenFile(outfile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for i, w := range words {
 		_, err := fmt.Fprintf(file, "%d [w_%d] %s\n", i, i, w)
 		if err != nil {
 			file.Close()
-			return nil, err
+			return nil, nil, err
 		}
 	}
-	return words, file.Close()
+	return words, lines, file.Close()
 }
 
-func loadWords(filename string) ([]string, error) {
+// loadWords は、テキストファイルの各行を発音記号列に変換し、前後に無音を加えた単語列として返します。
+// 空行は無視します。併せて、単語に対応する行の内容を返します（sp のみの行は空文字列）。
+// カタカナやローマ字はひらがなとして解釈し、解釈できない文字がある場合はその行と位置の一覧をエラーとして返します。
+// dict を指定すると、ユーザー辞書に登録された表記を読みに置換します。
+func loadWords(filename string, dict *UserDictionary) ([]string, []string, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer file.Close()
 
 	reader := bufio.NewReader(file)
 	words := []string{"silB"}
-	for {
+	lines := []string{}
+	errs := TranscriptErrors{}
+	for n := 1; ; n++ {
 		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		kana, nerr := normalizeTranscript(line, dict)
+		if nerr != nil {
+			terr := nerr.(*TranscriptError)
+			terr.Line = n
+			errs = append(errs, terr)
+		}
+		if w := kanaToPhonetic(kana); w != "" {
+			words = append(words, w)
+			if isPauseWord(w) {
+				lines = append(lines, "")
+			} else {
+				lines = append(lines, strings.TrimSpace(line))
+			}
+		}
 		if err == io.EOF {
 			break
-		} else if err != nil {
-			return nil, err
 		}
-		words = append(words, kanaToPhonetic(line))
+	}
+	if 0 < len(errs) {
+		return nil, nil, xerrors.Errorf("%s: 辞書に登録されていない語があります:\n%w", filename, errs)
 	}
 	words = append(words, "silE")
-	return words, nil
+	return words, lines, nil
+}
+
+// CheckTranscript は、テキストファイルに読みとして解釈できない文字がないかを検査します。
+// dict を指定すると、ユーザー辞書に登録された表記は読みとして解釈できるものとします。
+func CheckTranscript(filename string, dict *UserDictionary) error {
+	_, _, err := loadWords(filename, dict)
+	return err
+}
+
+// isPauseWord は、単語が無音の指定（sp）のみからなるかを返します。
+func isPauseWord(word string) bool {
+	for _, p := range strings.Fields(word) {
+		if p != "sp" {
+			return false
+		}
+	}
+	return true
+}
+
+// assignLines は、各セグメントがテキストファイルの何行目の発音であるかを設定します。
+// words は silB, 各行, silE の順に並んだ単語列です。sp のみの行はどの行にも属さないものとします。
+func (result *Result) assignLines(words, lines []string) {
+	result.Lines = lines
+	i := 0
+	for w, word := range words {
+		for range strings.Fields(word) {
+			if len(result.Segments) <= i {
+				return
+			}
+			if 0 < w && w <= len(lines) && lines[w-1] != "" {
+				result.Segments[i].Line = w - 1
+			}
+			i++
+		}
+	}
+}
+
+// Phrase は、テキストファイルの1行に対応する発音区間です。
+type Phrase struct {
+	Line      int
+	Text      string
+	BeginTime float64
+	EndTime   float64
+	Segments  []Segment
+}
+
+// Phrases は、テキストファイルの行ごとの発音区間を返します。
+// 行の前後にある sp は区間に含めません。
+func (result *Result) Phrases() []Phrase {
+	phrases := []Phrase{}
+	for _, seg := range result.Segments {
+		if seg.Line < 0 || len(result.Lines) <= seg.Line {
+			continue
+		}
+		n := len(phrases)
+		if n == 0 || phrases[n-1].Line != seg.Line {
+			phrases = append(phrases, Phrase{Line: seg.Line, Text: result.Lines[seg.Line]})
+			n++
+		}
+		phrases[n-1].Segments = append(phrases[n-1].Segments, seg)
+	}
+	for i := range phrases {
+		segs := phrases[i].Segments
+		for 1 < len(segs) && segs[0].Unit == "sp" {
+			segs = segs[1:]
+		}
+		for 1 < len(segs) && segs[len(segs)-1].Unit == "sp" {
+			segs = segs[:len(segs)-1]
+		}
+		phrases[i].BeginTime = segs[0].BeginTime
+		phrases[i].EndTime = segs[len(segs)-1].EndTime
+	}
+	return phrases
 }
 
 func generateDFA(num int, outfile string) error {
@@ -93,10 +191,10 @@ func saveAssetAsFile(assetname, filename string) error {
 	return ioutil.WriteFile(filename, data, 0644)
 }
 
-func Segmentate(wavfile, wordsfile, objPrefix string) (*Result, error) {
+func Segmentate(wavfile, wordsfile, objPrefix string, dict *UserDictionary) (*Result, error) {
 	log.Print("info: 発音タイミングを推定中...")
 
-	words, err := wordsToDict(wordsfile, objPrefix+".dict")
+	words, lines, err := wordsToDict(wordsfile, objPrefix+".dict", dict)
 	if err != nil {
 		return nil, err
 	}
@@ -117,5 +215,10 @@ func Segmentate(wavfile, wordsfile, objPrefix string) (*Result, error) {
 		"-palign", // optionally output phoneme alignments
 		"-input", "file",
 	}
-	return run(argv, wavfile)
+	result, err := run(argv, wavfile)
+	if err != nil {
+		return nil, err
+	}
+	result.assignLines(words, lines)
+	return result, nil
 }
@@ -0,0 +1,77 @@
+package julius
+
+import (
+	"strings"
+)
+
+// hmmPhones は、発音タイミングの推定に使用する音響モデル（segmentation-kit のモノフォンモデル）の音素の一覧です。
+var hmmPhones = map[string]bool{
+	"a": true, "i": true, "u": true, "e": true, "o": true,
+	"a:": true, "i:": true, "u:": true, "e:": true, "o:": true,
+	"b": true, "by": true, "ch": true, "d": true, "dy": true, "f": true,
+	"g": true, "gy": true, "h": true, "hy": true, "j": true, "k": true,
+	"ky": true, "m": true, "my": true, "n": true, "ny": true, "p": true,
+	"py": true, "r": true, "ry": true, "s": true, "sh": true, "t": true,
+	"ts": true, "w": true, "y": true, "z": true,
+	"N": true, "q": true, "sp": true, "silB": true, "silE": true,
+}
+
+// IsHMMPhone は、音素 p が音響モデルに含まれるかどうかを返します。
+func IsHMMPhone(p string) bool {
+	return hmmPhones[p]
+}
+
+// IsSpecial は、音素 p が促音・撥音・無音等の特殊な音素かどうかを返します。
+func IsSpecial(p string) bool {
+	_, ok := specials[p]
+	return ok
+}
+
+// Syllable は、テキストファイルの読みを音節ごとに区切った単位です。
+type Syllable struct {
+	Column   int    // 行頭からの文字数（1〜）
+	Kana     string // ひらがな、または発音記号
+	Phonemes []string
+	WordEnd  bool // 直後が空白または行末であるか
+}
+
+func isSmallKana(r rune) bool {
+	return strings.ContainsRune("ぁぃぅぇぉゃゅょゎ゛ー", r)
+}
+
+// SplitSyllables は、テキストファイルの1行の読みを音節ごとに区切り、それぞれを音素列に変換します。
+// 解釈できない文字がある場合は *TranscriptError を返します。
+func SplitSyllables(line string, dict *UserDictionary) ([]Syllable, error) {
+	kana, cols, err := normalizeTranscriptColumns(line, dict)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(kana)
+	result := []Syllable{}
+	for i := 0; i < len(runes); {
+		if runes[i] == ' ' {
+			i++
+			continue
+		}
+		j := i + 1
+		if runes[i] < 0x80 {
+			// 発音記号
+			for j < len(runes) && runes[j] != ' ' && runes[j] < 0x80 {
+				j++
+			}
+		} else {
+			for j < len(runes) && isSmallKana(runes[j]) {
+				j++
+			}
+		}
+		unit := string(runes[i:j])
+		result = append(result, Syllable{
+			Column:   cols[i] + 1,
+			Kana:     unit,
+			Phonemes: KanaToPhonetic(unit),
+			WordEnd:  j == len(runes) || runes[j] == ' ',
+		})
+		i = j
+	}
+	return result, nil
+}
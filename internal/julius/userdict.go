@@ -0,0 +1,145 @@
+package julius
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/xerrors"
+)
+
+// UserDictionary は、テキストファイル中の表記（漢字や英単語等）を読みに置換するユーザー辞書です。
+//
+// 辞書ファイルは1行に1語、表記と読みをタブで区切って記述します。
+// 読みはひらがな・カタカナ・ローマ字のいずれかで記述します。# で始まる行は無視します。
+type UserDictionary struct {
+	filename string
+	entries  map[string]string // 小文字化した表記 → 読み
+	maxLen   int               // 表記の最大文字数
+}
+
+func dictKey(surface string) string {
+	return strings.Map(unicode.ToLower, surface)
+}
+
+// LoadUserDictionary は、ユーザー辞書を読み込みます。ファイルが存在しない場合は空の辞書を返します。
+func LoadUserDictionary(filename string) (*UserDictionary, error) {
+	dict := &UserDictionary{filename: filename, entries: map[string]string{}}
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return dict, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for n := 1; ; n++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if s := strings.TrimSpace(line); s != "" && !strings.HasPrefix(s, "#") {
+			cols := strings.SplitN(line, "\t", 2)
+			if len(cols) < 2 || strings.TrimSpace(cols[0]) == "" {
+				return nil, fmt.Errorf("%s: %d 行目: 表記と読みをタブで区切って記述してください", filename, n)
+			}
+			reading := strings.TrimSpace(cols[1])
+			if _, err := normalizeTranscript(reading, nil); err != nil {
+				return nil, xerrors.Errorf("%s: %d 行目の読み: %w", filename, n, err)
+			}
+			dict.add(strings.TrimSpace(cols[0]), reading)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return dict, nil
+}
+
+func (dict *UserDictionary) add(surface, reading string) {
+	dict.entries[dictKey(surface)] = reading
+	if n := len([]rune(surface)); dict.maxLen < n {
+		dict.maxLen = n
+	}
+}
+
+// Len は、登録されている語の数を返します。
+func (dict *UserDictionary) Len() int {
+	return len(dict.entries)
+}
+
+func isASCIILetter(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
+
+// lookup は、runes[i:] の先頭に最長一致する表記を探し、その読みと表記の文字数を返します。
+// 英字で終わる表記は、直後に英字が続く場合には一致しないものとします。
+func (dict *UserDictionary) lookup(runes []rune, i int) (string, int) {
+	if dict == nil {
+		return "", 0
+	}
+	for n := dict.maxLen; 1 <= n; n-- {
+		if len(runes) < i+n {
+			continue
+		}
+		reading, ok := dict.entries[dictKey(string(runes[i:i+n]))]
+		if !ok {
+			continue
+		}
+		if isASCIILetter(runes[i+n-1]) && i+n < len(runes) && isASCIILetter(runes[i+n]) {
+			continue
+		}
+		return reading, n
+	}
+	return "", 0
+}
+
+// Learn は、発話内容の推定結果のうち、読みとして解釈できない表記（漢字等）を含む単語の読みを辞書に追加し、
+// 辞書ファイルに追記します。追加した語の数を返します。
+func (dict *UserDictionary) Learn(result *Result) (int, error) {
+	added := []string{}
+	for _, w := range result.Words {
+		surface := strings.TrimSpace(w.Surface)
+		if surface == "" || strings.HasPrefix(surface, "<") || len(w.Phonemes) == 0 {
+			continue
+		}
+		if _, ok := dict.entries[dictKey(surface)]; ok {
+			continue
+		}
+		if _, err := normalizeTranscript(surface, nil); err == nil {
+			continue
+		}
+		reading := joinKana(phoneticToKana(w.Phonemes))
+		if reading == "" {
+			continue
+		}
+		if _, err := normalizeTranscript(reading, nil); err != nil {
+			continue
+		}
+		dict.add(surface, reading)
+		added = append(added, surface+"\t"+reading+"\n")
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+	if b, err := ioutil.ReadFile(dict.filename); err == nil && 0 < len(b) && b[len(b)-1] != '\n' {
+		added[0] = "\n" + added[0]
+	}
+	file, err := os.OpenFile(dict.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range added {
+		if _, err := file.WriteString(line); err != nil {
+			file.Close()
+			return 0, err
+		}
+	}
+	return len(added), file.Close()
+}
@@ -0,0 +1,139 @@
+package julius
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestDictionary(t *testing.T, text string) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "userdict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "dict.txt")
+	if err := ioutil.WriteFile(filename, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename, func() { os.RemoveAll(dir) }
+}
+
+const testDictionary = "# コメント\r\n" +
+	"東京\tとうきょう\r\n" +
+	"東京タワー\tトウキョウタワー\n" +
+	"\n" +
+	"AI\tえーあい\n" +
+	"Go\tgo"
+
+func TestLoadUserDictionary(t *testing.T) {
+	filename, cleanup := writeTestDictionary(t, testDictionary)
+	defer cleanup()
+	dict, err := LoadUserDictionary(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dict.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", dict.Len())
+	}
+
+	dict, err = LoadUserDictionary(filename + ".missing")
+	if err != nil || dict.Len() != 0 {
+		t.Errorf("LoadUserDictionary() of a missing file = %v, %v, want empty dictionary", dict, err)
+	}
+
+	for _, text := range []string{"東京 とうきょう\n", "\tとうきょう\n", "東京\t#\n"} {
+		filename, cleanup := writeTestDictionary(t, text)
+		if _, err := LoadUserDictionary(filename); err == nil {
+			t.Errorf("LoadUserDictionary(%q) error = nil", text)
+		}
+		cleanup()
+	}
+}
+
+func TestUserDictionaryLookup(t *testing.T) {
+	filename, cleanup := writeTestDictionary(t, testDictionary)
+	defer cleanup()
+	dict, err := LoadUserDictionary(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text    string
+		i       int
+		reading string
+		n       int
+	}{
+		{text: "東京タワーに", reading: "トウキョウタワー", n: 5}, // 最長一致
+		{text: "東京タワ", reading: "とうきょう", n: 2},
+		{text: "東京に", reading: "とうきょう", n: 2},
+		{text: "いま東京", i: 2, reading: "とうきょう", n: 2},
+		{text: "AIです", reading: "えーあい", n: 2},
+		{text: "ai", reading: "えーあい", n: 2}, // 大文字・小文字を区別しない
+		{text: "AI.", reading: "えーあい", n: 2},
+		{text: "AIM"}, // 英単語の途中では一致しない
+		{text: "Gopher"},
+		{text: "GoGo"},
+		{text: "大阪"},
+		{text: ""},
+	}
+	for _, tt := range tests {
+		reading, n := dict.lookup([]rune(tt.text), tt.i)
+		if reading != tt.reading || n != tt.n {
+			t.Errorf("lookup(%q, %d) = %q, %d, want %q, %d", tt.text, tt.i, reading, n, tt.reading, tt.n)
+		}
+	}
+
+	var empty *UserDictionary
+	if reading, n := empty.lookup([]rune("東京"), 0); reading != "" || n != 0 {
+		t.Errorf("lookup() of nil dictionary = %q, %d", reading, n)
+	}
+}
+
+func TestUserDictionaryLearn(t *testing.T) {
+	filename, cleanup := writeTestDictionary(t, testDictionary)
+	defer cleanup()
+	dict, err := LoadUserDictionary(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &Result{Words: []Word{
+		{Surface: "<s>", Phonemes: []string{"sp"}},
+		{Surface: "渋谷", Phonemes: []string{"sh", "i", "b", "u", "y", "a"}},
+		{Surface: "東京", Phonemes: []string{"t", "o:", "ky", "o:"}},     // 登録済み
+		{Surface: "あした", Phonemes: []string{"a", "sh", "i", "t", "a"}}, // 読みとして解釈できる
+		{Surface: "品川", Phonemes: []string{"sh", "i", "n", "a", "g", "a", "w", "a"}},
+		{Surface: "謎"}, // 読みがない
+	}}
+	n, err := dict.Learn(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Learn() = %d, want 2", n)
+	}
+	if reading, _ := dict.lookup([]rune("渋谷"), 0); reading != "しぶや" {
+		t.Errorf("lookup(渋谷) = %q, want しぶや", reading)
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testDictionary + "\n渋谷\tしぶや\n品川\tしながわ\n"
+	if string(b) != want {
+		t.Errorf("dictionary file = %q, want %q", string(b), want)
+	}
+
+	reloaded, err := LoadUserDictionary(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Len() != 6 {
+		t.Errorf("Len() after reload = %d, want 6", reloaded.Len())
+	}
+	if n, err := reloaded.Learn(result); err != nil || n != 0 {
+		t.Errorf("Learn() of learned words = %d, %v, want 0", n, err)
+	}
+}
@@ -0,0 +1,193 @@
+package lint
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/but80/talklistener/internal/julius"
+	"github.com/but80/talklistener/internal/vsqx"
+)
+
+// Severity は、診断結果の重要度です。
+type Severity int
+
+const (
+	// Warning は、VSQXを生成できるものの、意図しない発音になる可能性がある箇所を表します。
+	Warning Severity = iota
+	// Error は、発音タイミングの推定やVSQXの生成に失敗する箇所を表します。
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic は、テキストファイルの検査結果の1項目です。
+type Diagnostic struct {
+	Line       int // 行番号（1〜）
+	Column     int // 行頭からの文字数（1〜）
+	Severity   Severity
+	Message    string
+	Suggestion string // 修正案
+}
+
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Column, d.Severity, d.Message)
+	if d.Suggestion != "" {
+		s += "（修正案: " + d.Suggestion + "）"
+	}
+	return s
+}
+
+// altPhones は、音響モデルに含まれない音素の代替となる音素です。
+var altPhones = map[string]string{
+	"zy": "j",
+	"ty": "ch",
+}
+
+// particles は、助詞として発音が変わるかなと、その記述方法です。
+var particles = map[string]string{
+	"は": "わ",
+	"へ": "え",
+}
+
+func vowelOf(phonemes []string, i int) (int, bool) {
+	if len(phonemes) <= i {
+		return -1, false
+	}
+	v, _ := julius.SplitLong(phonemes[i])
+	vi, ok := julius.Vowels[v]
+	return vi, ok
+}
+
+// checkSyllable は、音節の音素列を音響モデルの音素、発音の変換表、VSQXの発音記号と照合します。
+func checkSyllable(line int, s julius.Syllable) []Diagnostic {
+	result := []Diagnostic{}
+	add := func(sev Severity, msg, suggestion string) {
+		result = append(result, Diagnostic{Line: line, Column: s.Column, Severity: sev, Message: msg, Suggestion: suggestion})
+	}
+	if len(s.Phonemes) == 0 || s.Phonemes[0] == ":" {
+		add(Error, fmt.Sprintf("「%s」の長音記号の前に母音がありません", s.Kana), "ー を削除してください")
+		return result
+	}
+	for i := 0; i < len(s.Phonemes); i++ {
+		p := s.Phonemes[i]
+		if _, ok := vowelOf(s.Phonemes, i); ok {
+			continue
+		}
+		if _, long := julius.SplitLong(p); long {
+			add(Error, fmt.Sprintf("「%s」の長音記号の前に母音がありません", s.Kana), strings.Replace(s.Kana, "ー", "", -1))
+			continue
+		}
+		vi, hasVowel := vowelOf(s.Phonemes, i+1)
+		if !julius.IsHMMPhone(p) {
+			suggestion := ""
+			if alt, ok := altPhones[p]; ok {
+				suggestion = alt
+				if hasVowel {
+					suggestion = julius.Consonants[alt].Kana[vi]
+					if _, long := julius.SplitLong(s.Phonemes[i+1]); long {
+						suggestion += julius.LongVowelKana
+					}
+				}
+			}
+			add(Error, fmt.Sprintf("「%s」の音素 [%s] は音響モデルに含まれないため、発音タイミングを推定できません", s.Kana, p), suggestion)
+			if hasVowel {
+				i++
+			}
+			continue
+		}
+		if julius.IsSpecial(p) {
+			continue
+		}
+		cons, ok := julius.Consonants[p]
+		if !ok || (hasVowel && len(cons.Kana) <= vi) {
+			add(Error, fmt.Sprintf("「%s」の [%s] の発音を特定できません", s.Kana, p), "")
+			continue
+		}
+		if hasVowel {
+			if lyrics := cons.Kana[vi]; !vsqx.HasPhonemes(lyrics) {
+				add(Warning, fmt.Sprintf("歌詞「%s」の発音記号が定義されていないため、VSQX上で発音記号が固定されません", lyrics), "VOCALOID Editor 上で発音記号を設定してください")
+			}
+			i++
+		}
+	}
+	return result
+}
+
+// checkLine は、テキストファイルの1行を検査します。
+// 読みとして解釈できない箇所は、報告した上で空白とみなして残りの箇所の検査を続けます。
+func checkLine(n int, line string, dict *julius.UserDictionary) []Diagnostic {
+	result := []Diagnostic{}
+	var syllables []julius.Syllable
+	for {
+		var err error
+		syllables, err = julius.SplitSyllables(line, dict)
+		if err == nil {
+			break
+		}
+		terr, ok := err.(*julius.TranscriptError)
+		if !ok {
+			return append(result, Diagnostic{Line: n, Column: 1, Severity: Error, Message: err.Error()})
+		}
+		result = append(result, Diagnostic{
+			Line:       n,
+			Column:     terr.Column,
+			Severity:   Error,
+			Message:    fmt.Sprintf("「%s」は読みとして解釈できません", terr.Text),
+			Suggestion: "ひらがなで記述するか、ユーザー辞書に登録してください",
+		})
+		runes := []rune(line)
+		for i := terr.Column - 1; i < terr.Column-1+len([]rune(terr.Text)) && i < len(runes); i++ {
+			runes[i] = ' '
+		}
+		line = string(runes)
+	}
+	for i, s := range syllables {
+		result = append(result, checkSyllable(n, s)...)
+		if alt, ok := particles[s.Kana]; ok && s.WordEnd && 0 < i && !syllables[i-1].WordEnd {
+			result = append(result, Diagnostic{
+				Line:       n,
+				Column:     s.Column,
+				Severity:   Warning,
+				Message:    fmt.Sprintf("単語末尾の「%s」は表記どおりに発音されます（助詞の場合は「%s」と記述する必要があります）", s.Kana, alt),
+				Suggestion: alt,
+			})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Column < result[j].Column
+	})
+	return result
+}
+
+// Lint は、テキストファイルの各行の読みを検査し、問題のある箇所の一覧を返します。
+// dict を指定すると、ユーザー辞書に登録された表記は読みに置換してから検査します。
+func Lint(filename string, dict *julius.UserDictionary) ([]Diagnostic, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	result := []Diagnostic{}
+	reader := bufio.NewReader(file)
+	for n := 1; ; n++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		result = append(result, checkLine(n, strings.TrimRight(line, "\r\n"), dict)...)
+		if err == io.EOF {
+			break
+		}
+	}
+	return result, nil
+}
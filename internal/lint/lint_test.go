@@ -0,0 +1,100 @@
+package lint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type wantDiagnostic struct {
+	line       int
+	column     int
+	severity   Severity
+	suggestion string
+}
+
+func checkDiagnostics(t *testing.T, name string, got []Diagnostic, want []wantDiagnostic) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: got %d diagnostics, want %d: %v", name, len(got), len(want), got)
+		return
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Line != w.line || g.Column != w.column || g.Severity != w.severity || g.Suggestion != w.suggestion {
+			t.Errorf("%s: diagnostic %d = %q, want %d:%d: %s（修正案: %s）", name, i, g.String(), w.line, w.column, w.severity, w.suggestion)
+		}
+	}
+}
+
+func TestCheckLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []wantDiagnostic
+	}{
+		{line: ""},
+		{line: "こんにちわ"},
+		{line: "がっこう ゔぁいおりん てぃー"},
+		{line: "sp あ"},
+		{
+			line: "こんにちは",
+			want: []wantDiagnostic{{1, 5, Warning, "わ"}},
+		},
+		{
+			line: "わたしは がっこうへ",
+			want: []wantDiagnostic{{1, 4, Warning, "わ"}, {1, 10, Warning, "え"}},
+		},
+		{
+			// 1音節の単語は助詞とみなさない
+			line: "は へ",
+		},
+		{
+			line: "ーあ",
+			want: []wantDiagnostic{{1, 1, Error, "ー を削除してください"}},
+		},
+		{
+			line: "あ ずゃ",
+			want: []wantDiagnostic{{1, 3, Error, "じゃ"}},
+		},
+		{
+			line: "てゅー",
+			want: []wantDiagnostic{{1, 1, Error, "ちゅー"}},
+		},
+		{
+			line: "あいう ABC ええ てょ",
+			want: []wantDiagnostic{
+				{1, 6, Error, "ひらがなで記述するか、ユーザー辞書に登録してください"},
+				{1, 12, Error, "ちょ"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		checkDiagnostics(t, tt.line, checkLine(1, tt.line, nil), tt.want)
+	}
+}
+
+func TestLint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.txt")
+	text := "こんにちわ\r\nわたしは\r\n\r\nずゃ"
+	if err := ioutil.WriteFile(filename, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Lint(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDiagnostics(t, "Lint", got, []wantDiagnostic{
+		{2, 4, Warning, "わ"},
+		{4, 1, Error, "じゃ"},
+	})
+
+	if _, err := Lint(filepath.Join(dir, "missing.txt"), nil); err == nil {
+		t.Error("Lint() of a missing file: error = nil")
+	}
+}
@@ -0,0 +1,42 @@
+package vsqx
+
+import (
+	"strings"
+)
+
+// englishPhonemes は、日本語の発音記号と、それに近い英語ライブラリの発音記号です。
+// 表にない硬口蓋化した子音（k' 等）は、末尾の ' を除いた子音に j を続けて表します。
+// いずれも、i の直前では j を省きます。
+var englishPhonemes = map[string]string{
+	"a": "Q", "i": "i:", "M": "u:", "e": "e", "o": "O:",
+	"i_0": "I", "M_0": "U", // 無声化した母音は短く弱い母音で代用する
+	"k": "k", "g": "g", "N'": "g j", "s": "s", "S": "S", "z": "z", "dz": "z", "Z": "Z", "dZ": "dZ",
+	"t": "t", "ts": "t s", "tS": "tS", "d": "d", "n": "n", "J": "n j", "N\\": "n",
+	"h": "h", "C": "h j", `p\`: "f", "b": "b", "p": "p", "m": "m",
+	"4": "l", "j": "j", "w": "w",
+}
+
+// toEnglishPhonemes は、日本語の発音記号列を英語ライブラリの発音記号列に変換します。
+// 対応する発音記号がないものはそのまま残します。
+func toEnglishPhonemes(phnms string) string {
+	src := strings.Fields(phnms)
+	result := []string{}
+	for i, p := range src {
+		e, ok := englishPhonemes[p]
+		if !ok && strings.HasSuffix(p, "'") {
+			if e, ok = englishPhonemes[strings.TrimSuffix(p, "'")]; ok {
+				e += " j"
+			}
+		}
+		if !ok {
+			// 対応する発音記号がない
+			result = append(result, p)
+			continue
+		}
+		if i+1 < len(src) && (src[i+1] == "i" || src[i+1] == "i_0") {
+			e = strings.TrimSuffix(e, " j")
+		}
+		result = append(result, e)
+	}
+	return strings.Join(result, " ")
+}
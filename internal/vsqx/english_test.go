@@ -0,0 +1,69 @@
+package vsqx
+
+import (
+	"testing"
+)
+
+func TestToEnglishPhonemes(t *testing.T) {
+	tests := []struct {
+		phnms string
+		want  string
+	}{
+		{phnms: "k a", want: "k Q"},
+		{phnms: "ts M", want: "t s u:"},
+		{phnms: "k' a", want: "k j Q"},
+		{phnms: "4' o", want: "l j O:"},
+		{phnms: "N' o", want: "g j O:"},
+		{phnms: "J a", want: "n j Q"},
+		{phnms: "J i", want: "n i:"},
+		{phnms: "C i", want: "h i:"},
+		{phnms: "k' i", want: "k i:"},
+		{phnms: "J i_0", want: "n I"},
+		{phnms: "k i_0", want: "k I"},
+		{phnms: "s M_0", want: "s U"},
+		{phnms: "N\\", want: "n"},
+		{phnms: "Sil", want: "Sil"},
+	}
+	for _, tt := range tests {
+		if got := toEnglishPhonemes(tt.phnms); got != tt.want {
+			t.Errorf("toEnglishPhonemes(%q) = %q, want %q", tt.phnms, got, tt.want)
+		}
+	}
+}
+
+func TestAddNoteEnglish(t *testing.T) {
+	tests := []struct {
+		singer string
+		lyrics []string
+		want   []string
+		locks  []int
+	}{
+		{
+			singer: DefaultSinger,
+			lyrics: []string{"きゃ", "ー", "x"},
+			want:   []string{"k' a", "a", "4 a"},
+			locks:  []int{1, 1, 0},
+		},
+		{
+			singer: "KAITO_V3_English",
+			lyrics: []string{"きゃ", "ー", "x", "ー", "に"},
+			want:   []string{"k j Q", "Q", "l Q", "Q", "n i:"},
+			locks:  []int{1, 1, 0, 1, 1},
+		},
+	}
+	for _, tt := range tests {
+		track := New(tt.singer, 480, 120).VSTrack[0]
+		for i, lyrics := range tt.lyrics {
+			track.AddNote(64, i*480, (i+1)*480, 60, lyrics, "")
+		}
+		notes := track.MusicalPart[0].Note
+		if len(notes) != len(tt.want) {
+			t.Fatalf("%s: len(notes) = %d, want %d", tt.singer, len(notes), len(tt.want))
+		}
+		for i, n := range notes {
+			if n.Phnms.Data != tt.want[i] || n.Phnms.Lock != tt.locks[i] {
+				t.Errorf("%s: notes[%d] (%s) = %q (lock %d), want %q (lock %d)", tt.singer, i, tt.lyrics[i], n.Phnms.Data, n.Phnms.Lock, tt.want[i], tt.locks[i])
+			}
+		}
+	}
+}
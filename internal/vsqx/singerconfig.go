@@ -0,0 +1,160 @@
+package vsqx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/xerrors"
+)
+
+// SingerConfig は、シンガー定義ファイルに記述するシンガーの定義です。
+type SingerConfig struct {
+	CompID       string             `json:"compID"`
+	BS           int                `json:"bs"`
+	Range        []int              `json:"range,omitempty"`        // 推奨音域の [最低音, 最高音]（ノート番号）
+	PreUtterance map[string]float64 `json:"preUtterance,omitempty"` // 子音の種類ごとにノートの開始位置を早める時間（秒）
+	VoiceParam   *SingerVoiceParam  `json:"voiceParam,omitempty"`   // ボイスパラメータの既定値
+}
+
+// SingerVoiceParam は、シンガー定義ファイルに記述するボイスパラメータです。
+// 各値は VOCALOID の既定値からの差（-64〜63）です。
+type SingerVoiceParam struct {
+	BRE int `json:"bre"`
+	BRI int `json:"bri"`
+	CLE int `json:"cle"`
+	GEN int `json:"gen"`
+	OPE int `json:"ope"`
+}
+
+const (
+	voiceParamMin   = -64
+	voiceParamMax   = 63
+	preUtteranceMax = .2 // 子音の種類ごとにノートの開始位置を早める時間の上限（秒）
+)
+
+// DefaultSingersFile は、既定のシンガー定義ファイルの名前を返します。
+func DefaultSingersFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".talklistener", "singers.json")
+}
+
+func (c *SingerConfig) validate() error {
+	if c.CompID == "" {
+		return fmt.Errorf("compID を指定してください")
+	}
+	if c.BS != 0 && c.BS != 1 {
+		return fmt.Errorf("bs には 0（日本語）または 1（英語）を指定してください: %d", c.BS)
+	}
+	if len(c.Range) != 0 && (len(c.Range) != 2 || c.Range[1] < c.Range[0]) {
+		return fmt.Errorf("range には [最低音, 最高音] のノート番号を指定してください")
+	}
+	for _, n := range c.Range {
+		if n < 0 || 127 < n {
+			return fmt.Errorf("range のノート番号は 0〜127 で指定してください: %d", n)
+		}
+	}
+	for class, t := range c.PreUtterance {
+		if !IsConsonantClass(class) {
+			return fmt.Errorf("子音の種類 %s は定義されていません", class)
+		}
+		if t < 0 || preUtteranceMax < t {
+			return fmt.Errorf("preUtterance は 0〜%g 秒で指定してください: %s: %g", preUtteranceMax, class, t)
+		}
+	}
+	if p := c.VoiceParam; p != nil {
+		for _, v := range []int{p.BRE, p.BRI, p.CLE, p.GEN, p.OPE} {
+			if v < voiceParamMin || voiceParamMax < v {
+				return fmt.Errorf("voiceParam の値は %d〜%d で指定してください: %d", voiceParamMin, voiceParamMax, v)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *SingerConfig) def() singerDef {
+	d := singerDef{compID: c.CompID, bs: c.BS, preUtterance: c.PreUtterance}
+	if len(c.Range) == 2 {
+		d.noteRange = NoteRange{Low: c.Range[0], High: c.Range[1]}
+	}
+	if p := c.VoiceParam; p != nil {
+		d.voiceParam = VoiceParam{BRE: p.BRE, BRI: p.BRI, CLE: p.CLE, GEN: p.GEN, OPE: p.OPE}
+	}
+	return d
+}
+
+// ReadSingers は、シンガー定義ファイルを読み込みます。
+func ReadSingers(filename string) (map[string]*SingerConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]*SingerConfig{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, xerrors.Errorf("%s: %w", filename, err)
+	}
+	for name, c := range result {
+		if c == nil {
+			return nil, fmt.Errorf("%s: シンガー %s の定義が空です", filename, name)
+		}
+		if err := c.validate(); err != nil {
+			return nil, xerrors.Errorf("%s: シンガー %s: %w", filename, name, err)
+		}
+	}
+	return result, nil
+}
+
+// WriteSingers は、シンガー定義ファイルを保存します。
+func WriteSingers(filename string, singers map[string]*SingerConfig) error {
+	data, err := json.MarshalIndent(singers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// LoadSingers は、シンガー定義ファイルを読み込み、組み込みのシンガーの定義に追加します。
+// 組み込みのシンガーと同じ名前のシンガーは、ファイルの定義で置き換えます。
+// 読み込んだシンガーの名前を返します。
+func LoadSingers(filename string) ([]string, error) {
+	singers, err := ReadSingers(filename)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name, c := range singers {
+		singerDefs[name] = c.def()
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ImportSingers は、VSQXファイルのボイス設定からシンガーの定義（compID, vBS, 名前, ボイスパラメータ）を抽出します。
+func ImportSingers(filename string) (map[string]*SingerConfig, error) {
+	vsq, err := Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]*SingerConfig{}
+	for _, v := range vsq.VoiceTable.Voice {
+		if v.VoiceName.Data == "" || v.CompID.Data == "" {
+			continue
+		}
+		c := &SingerConfig{CompID: v.CompID.Data, BS: v.BS}
+		if p := v.VoiceParam; p.BRE != 0 || p.BRI != 0 || p.CLE != 0 || p.GEN != 0 || p.OPE != 0 {
+			c.VoiceParam = &SingerVoiceParam{BRE: p.BRE, BRI: p.BRI, CLE: p.CLE, GEN: p.GEN, OPE: p.OPE}
+		}
+		result[v.VoiceName.Data] = c
+	}
+	return result, nil
+}
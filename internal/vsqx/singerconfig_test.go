@@ -0,0 +1,106 @@
+package vsqx
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSingerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  SingerConfig
+		wantErr bool
+	}{
+		{name: "minimal", config: SingerConfig{CompID: "X"}},
+		{name: "english", config: SingerConfig{CompID: "X", BS: 1}},
+		{name: "range", config: SingerConfig{CompID: "X", Range: []int{55, 76}}},
+		{name: "full range", config: SingerConfig{CompID: "X", Range: []int{0, 127}}},
+		{name: "no compID", config: SingerConfig{}, wantErr: true},
+		{name: "invalid bs", config: SingerConfig{CompID: "X", BS: 2}, wantErr: true},
+		{name: "range with one value", config: SingerConfig{CompID: "X", Range: []int{60}}, wantErr: true},
+		{name: "reversed range", config: SingerConfig{CompID: "X", Range: []int{76, 55}}, wantErr: true},
+		{name: "negative note", config: SingerConfig{CompID: "X", Range: []int{-1, 60}}, wantErr: true},
+		{name: "note above 127", config: SingerConfig{CompID: "X", Range: []int{60, 128}}, wantErr: true},
+		{name: "preUtterance", config: SingerConfig{CompID: "X", PreUtterance: map[string]float64{"plosive": .015}}},
+		{name: "unknown consonant class", config: SingerConfig{CompID: "X", PreUtterance: map[string]float64{"vowel": .01}}, wantErr: true},
+		{name: "negative preUtterance", config: SingerConfig{CompID: "X", PreUtterance: map[string]float64{"plosive": -.01}}, wantErr: true},
+		{name: "too long preUtterance", config: SingerConfig{CompID: "X", PreUtterance: map[string]float64{"plosive": .5}}, wantErr: true},
+		{name: "voiceParam", config: SingerConfig{CompID: "X", VoiceParam: &SingerVoiceParam{BRE: -64, GEN: 63}}},
+		{name: "voiceParam out of range", config: SingerConfig{CompID: "X", VoiceParam: &SingerVoiceParam{OPE: 64}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSingerPreUtterance(t *testing.T) {
+	saved := singerDefs["X"]
+	defer func() {
+		if saved.compID == "" {
+			delete(singerDefs, "X")
+		} else {
+			singerDefs["X"] = saved
+		}
+	}()
+	c := SingerConfig{CompID: "X", PreUtterance: map[string]float64{"plosive": .03, "nasal": .005}, VoiceParam: &SingerVoiceParam{GEN: 10}}
+	singerDefs["X"] = c.def()
+
+	got := SingerPreUtterance("X")
+	want := map[string]float64{"plosive": .03, "affricate": defaultPreUtterance["affricate"], "fricative": defaultPreUtterance["fricative"], "nasal": .005}
+	if len(got) != len(want) {
+		t.Fatalf("SingerPreUtterance() = %v, want %v", got, want)
+	}
+	for class, v := range want {
+		if got[class] != v {
+			t.Errorf("SingerPreUtterance()[%s] = %g, want %g", class, got[class], v)
+		}
+	}
+	got["plosive"] = 1
+	if defaultPreUtterance["plosive"] == 1 || singerDefs["X"].preUtterance["plosive"] == 1 {
+		t.Error("SingerPreUtterance() の戻り値の変更が定義に影響しています")
+	}
+
+	vsq := New(DefaultSinger, 480, 120)
+	track := vsq.AddTrack("X")
+	if p := vsq.Voice(track).VoiceParam; p.GEN != 10 {
+		t.Errorf("AddTrack() VoiceParam.GEN = %d, want 10", p.GEN)
+	}
+}
+
+func TestReadSingers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "singers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{name: "valid", json: `{"X": {"compID": "XXXX", "bs": 1, "range": [55, 76]}}`},
+		{name: "empty", json: `{}`},
+		{name: "null entry", json: `{"X": null}`, wantErr: true},
+		{name: "invalid entry", json: `{"X": {"bs": 0}}`, wantErr: true},
+		{name: "broken", json: `{"X": `, wantErr: true},
+	}
+	for _, tt := range tests {
+		filename := filepath.Join(dir, "singers.json")
+		if err := ioutil.WriteFile(filename, []byte(tt.json), 0644); err != nil {
+			t.Fatal(err)
+		}
+		singers, err := ReadSingers(filename)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ReadSingers() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && tt.name == "valid" && (singers["X"].CompID != "XXXX" || singers["X"].BS != 1) {
+			t.Errorf("%s: ReadSingers() = %+v", tt.name, singers["X"])
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"math"
 	"sort"
+	"strings"
 )
 
 // https://github.com/KentoW/json2vsqx を参考にさせていただきました。
@@ -34,7 +35,7 @@ type Voice struct {
 
 type VoiceTable struct {
 	XMLName xml.Name `xml:"vVoiceTable"`
-	Voice   Voice
+	Voice   []Voice  `xml:"vVoice"`
 }
 
 type MasterUnit struct {
@@ -78,7 +79,7 @@ type KaraokeUnit struct {
 type Mixer struct {
 	XMLName     xml.Name `xml:"mixer"`
 	MasterUnit  MasterUnit
-	VSUnit      VSUnit
+	VSUnit      []VSUnit
 	SEUnit      SEUnit
 	KaraokeUnit KaraokeUnit
 }
@@ -119,6 +120,18 @@ type Attr struct {
 	ID      string   `xml:"id,attr"`
 }
 
+type SeqElem struct {
+	XMLName xml.Name `xml:"elem"`
+	PosNrm  int      `xml:"posNrm"`
+	Elv     int      `xml:"elv"`
+}
+
+type SeqAttr struct {
+	XMLName xml.Name  `xml:"seqAttr"`
+	ID      string    `xml:"id,attr"`
+	Elem    []SeqElem `xml:"elem"`
+}
+
 type Singer struct {
 	XMLName xml.Name `xml:"singer"`
 	PosTick int      `xml:"posTick"`
@@ -127,14 +140,25 @@ type Singer struct {
 }
 
 type Note struct {
-	XMLName   xml.Name `xml:"note"`
-	PosTick   int      `xml:"posTick"`
-	DurTick   int      `xml:"durTick"`
-	NoteNum   int      `xml:"noteNum"`
-	Velocity  int      `xml:"velocity"`
-	Lyric     CData    `xml:"lyric"`
-	Phnms     CData    `xml:"phnms"`
-	NoteStyle []Attr   `xml:"noteStyle>attr"`
+	XMLName   xml.Name  `xml:"note"`
+	PosTick   int       `xml:"posTick"`
+	DurTick   int       `xml:"durTick"`
+	NoteNum   int       `xml:"noteNum"`
+	Velocity  int       `xml:"velocity"`
+	Lyric     CData     `xml:"lyric"`
+	Phnms     CData     `xml:"phnms"`
+	NoteStyle []Attr    `xml:"noteStyle>attr"`
+	NoteSeq   []SeqAttr `xml:"noteStyle>seqAttr"`
+}
+
+func (note *Note) setStyle(id string, value int) {
+	for i := range note.NoteStyle {
+		if note.NoteStyle[i].ID == id {
+			note.NoteStyle[i].Value = value
+			return
+		}
+	}
+	note.NoteStyle = append(note.NoteStyle, Attr{ID: id, Value: value})
 }
 
 type MCtrl struct {
@@ -156,12 +180,28 @@ type MusicalPart struct {
 	Note        []Note  `xml:"note"`
 }
 
+// partOriginTick は、シーケンスの先頭（プリメジャーの直後）の位置です。
+const partOriginTick = 7680
+
+// BeginTick は、パートの開始位置をシーケンスの先頭からのTick数で返します。
+func (part *MusicalPart) BeginTick() int {
+	return part.PosTick - partOriginTick
+}
+
+// EndTick は、パートの終了位置をシーケンスの先頭からのTick数で返します。
+func (part *MusicalPart) EndTick() int {
+	return part.BeginTick() + part.PlayTime
+}
+
 type VSTrack struct {
 	XMLName     xml.Name `xml:"vsTrack"`
 	VSTrackNo   int      `xml:"vsTrackNo"`
 	TrackName   CData    `xml:"trackName"`
 	Comment     CData    `xml:"comment"`
-	MusicalPart MusicalPart
+	MusicalPart []*MusicalPart
+
+	noteCount int    `xml:"-"`
+	noteStyle []Attr `xml:"-"` // AddNote で追加するノートのスタイルの既定値の上書き
 }
 
 type SETrack struct {
@@ -188,12 +228,10 @@ type VSQ3 struct {
 	VoiceTable     VoiceTable
 	Mixer          Mixer
 	MasterTrack    MasterTrack
-	VSTrack        VSTrack
+	VSTrack        []*VSTrack
 	SETrack        SETrack
 	KaraokeTrack   KaraokeTrack
 	AUX            AUX
-
-	noteCount int `xml:"-"`
 }
 
 func Load(filename string) (*VSQ3, error) {
@@ -205,15 +243,13 @@ func Load(filename string) (*VSQ3, error) {
 	if err := xml.Unmarshal(x, &result); err != nil {
 		return nil, err
 	}
-	result.normalize("")
+	result.normalize()
 	return &result, nil
 }
 
 func New(singer string, resolution int, bpm float64) *VSQ3 {
 	var vsq3 VSQ3
-	vsq3.normalize(singer)
-	vsq3.Mixer.VSUnit.SendLevel = -898
-	vsq3.Mixer.VSUnit.Pan = 64
+	vsq3.normalize()
 	vsq3.Mixer.SEUnit.SendLevel = -898
 	vsq3.Mixer.SEUnit.Pan = 64
 	vsq3.Mixer.KaraokeUnit.Vol = -129
@@ -222,9 +258,34 @@ func New(singer string, resolution int, bpm float64) *VSQ3 {
 	vsq3.MasterTrack.TimeSig.Nume = 4
 	vsq3.MasterTrack.TimeSig.Denomi = 4
 	vsq3.MasterTrack.Tempo.BPM = int(math.Round(bpm * 100))
-	vsq3.VSTrack.MusicalPart.PosTick = 7680
-	vsq3.VSTrack.MusicalPart.PlayTime = 614400 // ?
-	vsq3.VSTrack.MusicalPart.PartStyle = []Attr{
+	vsq3.AddTrack(singer)
+
+	return &vsq3
+}
+
+// AddTrack は、指定したシンガーのトラックを追加します。
+func (vsq3 *VSQ3) AddTrack(singer string) *VSTrack {
+	no := len(vsq3.VSTrack)
+	pc := len(vsq3.VoiceTable.Voice)
+	vsq3.VoiceTable.Voice = append(vsq3.VoiceTable.Voice, Voice{PC: pc})
+	voice := &vsq3.VoiceTable.Voice[pc]
+	if d, ok := singerDefs[singer]; ok {
+		voice.BS = d.bs
+		voice.CompID.Data = d.compID
+		voice.VoiceName.Data = singer
+		voice.VoiceParam = d.voiceParam
+	}
+
+	vsq3.Mixer.VSUnit = append(vsq3.Mixer.VSUnit, VSUnit{
+		VSTrackNo: no,
+		SendLevel: -898,
+		Pan:       64,
+	})
+
+	part := &MusicalPart{}
+	part.PosTick = partOriginTick
+	part.PlayTime = 614400 // FitParts で内容に合わせて調整する
+	part.PartStyle = []Attr{
 		{ID: "accent", Value: 50},
 		{ID: "bendDep", Value: 8},
 		{ID: "bendLen", Value: 0},
@@ -233,56 +294,101 @@ func New(singer string, resolution int, bpm float64) *VSQ3 {
 		{ID: "opening", Value: 127},
 		{ID: "risePort", Value: 0},
 	}
+	part.Singer.BS = voice.BS
+	part.Singer.PC = voice.PC
+	track := &VSTrack{VSTrackNo: no, MusicalPart: []*MusicalPart{part}}
+	track.normalize()
+	vsq3.VSTrack = append(vsq3.VSTrack, track)
+	return track
+}
+
+// Voice は、トラックに割り当てられたシンガーのボイス設定を返します。
+func (vsq3 *VSQ3) Voice(track *VSTrack) *Voice {
+	for i := range vsq3.VoiceTable.Voice {
+		if vsq3.VoiceTable.Voice[i].PC == track.MusicalPart[0].Singer.PC {
+			return &vsq3.VoiceTable.Voice[i]
+		}
+	}
+	return nil
+}
 
-	return &vsq3
+// NoteRange は、ノート番号の範囲です。
+type NoteRange struct {
+	Low  int
+	High int
+}
+
+// Center は、範囲の中央のノート番号を返します。
+func (r NoteRange) Center() int {
+	return (r.Low + r.High) / 2
+}
+
+// Contains は、ノート番号 note が範囲に含まれるかどうかを返します。
+func (r NoteRange) Contains(note int) bool {
+	return r.Low <= note && note <= r.High
 }
 
+// おおよその推奨音域（音名は VOCALOID Editor と同じく C3 = 60）
+var (
+	rangeFemale    = NoteRange{Low: 55, High: 76} // G2〜E4
+	rangeFemaleLow = NoteRange{Low: 53, High: 72} // F2〜C4
+	rangeMale      = NoteRange{Low: 45, High: 67} // A1〜G3
+	rangeMaleHigh  = NoteRange{Low: 50, High: 71} // D2〜B3
+)
+
 type singerDef struct {
-	compID string
-	bs     int
+	compID       string
+	bs           int
+	noteRange    NoteRange
+	preUtterance map[string]float64 // defaultPreUtterance を上書きする、子音の種類ごとの値（秒）
+	voiceParam   VoiceParam         // ボイスパラメータの既定値（VOCALOID の既定値からの差）
+}
+
+// defaultPreUtterance は、子音の種類ごとにノートの開始位置を母音の開始位置から早める時間（秒）の既定値です。
+// 全シンガー共通のおおよその値で、子音の立ち上がりが遅いものほど大きくしています。
+var defaultPreUtterance = map[string]float64{
+	"plosive":   .01,
+	"affricate": .015,
+	"fricative": .02,
 }
 
 var DefaultSinger = "Yukari_Onn"
 
 // Pull Request募集中
 var singerDefs = map[string]singerDef{
-	"CUL":                {compID: "BCBG86S4FSYMTCBK", bs: 0},
-	"DEX":                {compID: "BEPP62G3DDXLRECA", bs: 1},
-	"IA":                 {compID: "BLRGDDR4M3WM2LC6", bs: 0},
-	"Iroha(V2)":          {compID: "BMKN7HT9EWTTSMCL", bs: 0},
-	"KAITO_V3_English":   {compID: "BNGW7FG7E5TRSNC3", bs: 1},
-	"KAITO_V3_Soft":      {compID: "BKGKCC96L2TPZKAC", bs: 0},
-	"KAITO_V3_Straight":  {compID: "BDPEA722HT3KXDC4", bs: 0},
-	"KAITO_V3_Whisper":   {compID: "BDHEB7W2KTWKYDC5", bs: 0},
-	"LEN_V4X_Cold":       {compID: "BMGD88HZFLTHTMC7", bs: 0},
-	"LEN_V4X_Power_EVEC": {compID: "BKPLC6S7LH3RZKC8", bs: 0},
-	"LEN_V4X_Serious":    {compID: "BKFFF663PHSL4KB6", bs: 0},
-	"LEN_V4_English":     {compID: "BMFX98L8GLSSWMD3", bs: 1},
-	"Len_ACT2(V2)":       {compID: "BMLBDHXXMWYF2MBE", bs: 0},
-	"Luka_ENG(V2)":       {compID: "BHLNEE62NRYK3HD2", bs: 1},
-	"Luka_JPN(V2)":       {compID: "BCMDC9MZLKZHZCB4", bs: 0},
-	"Miku(V2)":           {compID: "BHHN4EF9BRWTNHAB", bs: 0},
-	"RIN_V4X_Power_EVEC": {compID: "BKKP765AEHXWSKDB", bs: 0},
-	"RIN_V4X_Sweet":      {compID: "BLECA76YHKRGXLB7", bs: 0},
-	"RIN_V4X_Warm":       {compID: "BDHEBZG2KCWKYDC5", bs: 0},
-	"RIN_V4_English":     {compID: "BXENFF42PWRK4XE7", bs: 1},
-	"Rin_ACT2(V2)":       {compID: "BEKF6B63DMXLRECA", bs: 0},
-	"VY1V3":              {compID: "BDRE87E2FTTKTDBA", bs: 0},
-	"VY2V3":              {compID: "BCXDC6CZLSZHZCB4", bs: 0},
-	"VY2V3_falsetto":     {compID: "BDSEB7L2KTWKYDC5", bs: 0},
-	"Yukari":             {compID: "BMGK9EC6G4RPWMB3", bs: 0},
-	"Yukari_Jun":         {compID: "BDKCEZEYNCTG3DBF", bs: 0},
-	"Yukari_Lin":         {compID: "BKLM76B8EHWSSKBB", bs: 0},
-	"Yukari_Onn":         {compID: "BNRCB9XYKM2GYNCE", bs: 0},
+	"CUL":                {compID: "BCBG86S4FSYMTCBK", bs: 0, noteRange: rangeFemale},
+	"DEX":                {compID: "BEPP62G3DDXLRECA", bs: 1, noteRange: rangeMaleHigh},
+	"IA":                 {compID: "BLRGDDR4M3WM2LC6", bs: 0, noteRange: rangeFemale},
+	"Iroha(V2)":          {compID: "BMKN7HT9EWTTSMCL", bs: 0, noteRange: rangeFemale},
+	"KAITO_V3_English":   {compID: "BNGW7FG7E5TRSNC3", bs: 1, noteRange: rangeMale},
+	"KAITO_V3_Soft":      {compID: "BKGKCC96L2TPZKAC", bs: 0, noteRange: rangeMale},
+	"KAITO_V3_Straight":  {compID: "BDPEA722HT3KXDC4", bs: 0, noteRange: rangeMale},
+	"KAITO_V3_Whisper":   {compID: "BDHEB7W2KTWKYDC5", bs: 0, noteRange: rangeMale},
+	"LEN_V4X_Cold":       {compID: "BMGD88HZFLTHTMC7", bs: 0, noteRange: rangeMaleHigh},
+	"LEN_V4X_Power_EVEC": {compID: "BKPLC6S7LH3RZKC8", bs: 0, noteRange: rangeMaleHigh},
+	"LEN_V4X_Serious":    {compID: "BKFFF663PHSL4KB6", bs: 0, noteRange: rangeMaleHigh},
+	"LEN_V4_English":     {compID: "BMFX98L8GLSSWMD3", bs: 1, noteRange: rangeMaleHigh},
+	"Len_ACT2(V2)":       {compID: "BMLBDHXXMWYF2MBE", bs: 0, noteRange: rangeMaleHigh},
+	"Luka_ENG(V2)":       {compID: "BHLNEE62NRYK3HD2", bs: 1, noteRange: rangeFemaleLow},
+	"Luka_JPN(V2)":       {compID: "BCMDC9MZLKZHZCB4", bs: 0, noteRange: rangeFemaleLow},
+	"Miku(V2)":           {compID: "BHHN4EF9BRWTNHAB", bs: 0, noteRange: rangeFemale},
+	"RIN_V4X_Power_EVEC": {compID: "BKKP765AEHXWSKDB", bs: 0, noteRange: rangeFemale},
+	"RIN_V4X_Sweet":      {compID: "BLECA76YHKRGXLB7", bs: 0, noteRange: rangeFemale},
+	"RIN_V4X_Warm":       {compID: "BDHEBZG2KCWKYDC5", bs: 0, noteRange: rangeFemale},
+	"RIN_V4_English":     {compID: "BXENFF42PWRK4XE7", bs: 1, noteRange: rangeFemale},
+	"Rin_ACT2(V2)":       {compID: "BEKF6B63DMXLRECA", bs: 0, noteRange: rangeFemale},
+	"VY1V3":              {compID: "BDRE87E2FTTKTDBA", bs: 0, noteRange: rangeFemaleLow},
+	"VY2V3":              {compID: "BCXDC6CZLSZHZCB4", bs: 0, noteRange: rangeMale},
+	"VY2V3_falsetto":     {compID: "BDSEB7L2KTWKYDC5", bs: 0, noteRange: rangeMaleHigh},
+	"Yukari":             {compID: "BMGK9EC6G4RPWMB3", bs: 0, noteRange: rangeFemale},
+	"Yukari_Jun":         {compID: "BDKCEZEYNCTG3DBF", bs: 0, noteRange: rangeFemale},
+	"Yukari_Lin":         {compID: "BKLM76B8EHWSSKBB", bs: 0, noteRange: rangeFemale},
+	"Yukari_Onn":         {compID: "BNRCB9XYKM2GYNCE", bs: 0, noteRange: rangeFemale},
 }
 
 func Singers() []string {
 	result := []string{}
-	for s, d := range singerDefs {
-		if d.bs != 0 {
-			// TODO: 英語DB対応
-			continue
-		}
+	for s := range singerDefs {
 		result = append(result, s)
 	}
 	sort.Strings(result)
@@ -290,19 +396,155 @@ func Singers() []string {
 }
 
 func IsValidSinger(singer string) bool {
+	_, ok := singerDefs[singer]
+	return ok
+}
+
+// IsEnglishSinger は、シンガーが英語ライブラリかどうかを返します。
+func IsEnglishSinger(singer string) bool {
+	return singerDefs[singer].bs == 1
+}
+
+// SingerRange は、シンガーの推奨音域を返します。
+func SingerRange(singer string) (NoteRange, bool) {
 	d, ok := singerDefs[singer]
-	if !ok {
-		return false
+	if !ok || d.noteRange.High == 0 {
+		return NoteRange{}, false
 	}
-	// TODO: 英語DB対応
-	return d.bs == 0
+	return d.noteRange, true
 }
 
-func (vsq3 *VSQ3) isEnglish() bool {
-	return vsq3.VoiceTable.Voice.BS == 1
+// SingerPreUtterance は、シンガーの子音の種類ごとにノートの開始位置を早める時間（秒）を返します。
+func SingerPreUtterance(singer string) map[string]float64 {
+	result := map[string]float64{}
+	for class, t := range defaultPreUtterance {
+		result[class] = t
+	}
+	for class, t := range singerDefs[singer].preUtterance {
+		result[class] = t
+	}
+	return result
+}
+
+// consonantClasses は、子音の発音記号と、その種類です。
+var consonantClasses = map[string]string{
+	"k": "plosive", "k'": "plosive", "g": "plosive", "g'": "plosive",
+	"t": "plosive", "t'": "plosive", "d": "plosive", "d'": "plosive",
+	"p": "plosive", "p'": "plosive", "b": "plosive", "b'": "plosive",
+	"ts": "affricate", "tS": "affricate", "dz": "affricate", "dZ": "affricate",
+	"s": "fricative", "S": "fricative", "z": "fricative", "Z": "fricative",
+	"h": "fricative", "C": "fricative", `p\`: "fricative", `p\'`: "fricative",
+	"m": "nasal", "m'": "nasal", "n": "nasal", "n'": "nasal", "N": "nasal",
+	"4": "flap", "4'": "flap",
+	"j": "approximant", "w": "approximant",
+}
+
+// ConsonantClasses は、ConsonantClass が返す子音の種類の一覧です。
+var ConsonantClasses = []string{"plosive", "affricate", "fricative", "nasal", "flap", "approximant"}
+
+// ConsonantClass は、子音の発音記号 phoneme の種類（破裂音 "plosive" 等）を返します。
+func ConsonantClass(phoneme string) string {
+	return consonantClasses[phoneme]
 }
 
-func (vsq3 *VSQ3) normalize(singer string) {
+// IsConsonantClass は、class が子音の種類として定義されているかどうかを返します。
+func IsConsonantClass(class string) bool {
+	for _, c := range ConsonantClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func (track *VSTrack) isEnglish() bool {
+	return track.MusicalPart[0].Singer.BS == 1
+}
+
+// SplitPart は、最後のパートを tick（シーケンスの先頭からのTick数）の位置で分割し、後半のパートを返します。
+// 後半のパートには、前半のパートの設定が引き継がれます。
+func (track *VSTrack) SplitPart(tick int) *MusicalPart {
+	last := track.MusicalPart[len(track.MusicalPart)-1]
+	end := last.EndTick()
+	part := &MusicalPart{}
+	*part = *last
+	part.PartStyle = append([]Attr{}, last.PartStyle...)
+	part.MCtrl = nil
+	part.Note = nil
+	part.PosTick = partOriginTick + tick
+	part.PlayTime = end - tick
+	if part.PlayTime <= 0 {
+		part.PlayTime = last.PlayTime
+	}
+	last.PlayTime = tick - last.BeginTick()
+	track.MusicalPart = append(track.MusicalPart, part)
+	return part
+}
+
+// PartAt は、tick（シーケンスの先頭からのTick数）の位置を含むパートを返します。
+func (track *VSTrack) PartAt(tick int) *MusicalPart {
+	for i := len(track.MusicalPart) - 1; 0 < i; i-- {
+		if track.MusicalPart[i].BeginTick() <= tick {
+			return track.MusicalPart[i]
+		}
+	}
+	return track.MusicalPart[0]
+}
+
+// FitParts は、各パートの長さを含まれるノートに合わせて調整し、ノートのないパートを取り除きます。
+// パートが次のパートと重ならないよう、次のパートの開始位置を越えるノートは短くします。
+// パートの範囲外となるコントロールも取り除きます。
+func (track *VSTrack) FitParts() {
+	parts := []*MusicalPart{}
+	for _, part := range track.MusicalPart {
+		if 0 < len(part.Note) {
+			parts = append(parts, part)
+		}
+	}
+	for i, part := range parts {
+		end := 0
+		for _, note := range part.Note {
+			if t := note.PosTick + note.DurTick; end < t {
+				end = t
+			}
+		}
+		if i+1 < len(parts) {
+			if limit := parts[i+1].PosTick - part.PosTick; limit < end {
+				for k := range part.Note {
+					note := &part.Note[k]
+					if limit < note.PosTick+note.DurTick {
+						note.DurTick = limit - note.PosTick
+					}
+				}
+				end = limit
+			}
+		}
+		part.PlayTime = end
+		mctrl := []MCtrl{}
+		for _, c := range part.MCtrl {
+			if c.PosTick <= end {
+				mctrl = append(mctrl, c)
+			}
+		}
+		part.MCtrl = mctrl
+	}
+	if len(parts) == 0 {
+		parts = track.MusicalPart[:1]
+	}
+	track.MusicalPart = parts
+}
+
+// lastNotePart は、最後に追加したノートを含むパートを返します。
+func (track *VSTrack) lastNotePart() *MusicalPart {
+	for i := len(track.MusicalPart) - 1; 0 <= i; i-- {
+		if 0 < len(track.MusicalPart[i].Note) {
+			return track.MusicalPart[i]
+		}
+	}
+	return nil
+}
+
+func (vsq3 *VSQ3) normalize() {
 	if vsq3.XMLNS == "" {
 		vsq3.XMLNS = "http://www.yamaha.co.jp/vocaloid/schema/vsq3/"
 	}
@@ -318,43 +560,50 @@ func (vsq3 *VSQ3) normalize(singer string) {
 	if vsq3.Version.Data == "" {
 		vsq3.Version.Data = "3.0.0.11"
 	}
-	if _, ok := singerDefs[singer]; ok {
-		vsq3.VoiceTable.Voice.BS = singerDefs[singer].bs
-		vsq3.VoiceTable.Voice.CompID.Data = singerDefs[singer].compID
-		vsq3.VoiceTable.Voice.VoiceName.Data = singer
-	}
 	if vsq3.MasterTrack.SeqName.Data == "" {
 		vsq3.MasterTrack.SeqName.Data = "none"
 	}
 	if vsq3.MasterTrack.Comment.Data == "" {
 		vsq3.MasterTrack.Comment.Data = "none"
 	}
-	if vsq3.VSTrack.TrackName.Data == "" {
-		vsq3.VSTrack.TrackName.Data = "Track"
+	for _, track := range vsq3.VSTrack {
+		track.normalize()
 	}
-	if vsq3.VSTrack.Comment.Data == "" {
-		vsq3.VSTrack.Comment.Data = "Track"
+	if vsq3.AUX.AUXID.Data == "" {
+		vsq3.AUX.AUXID.Data = "AUX_VST_HOST_CHUNK_INFO"
 	}
-	if vsq3.VSTrack.MusicalPart.PartName.Data == "" {
-		vsq3.VSTrack.MusicalPart.PartName.Data = "NewPart"
+	if vsq3.AUX.Content.Data == "" {
+		vsq3.AUX.Content.Data = "VlNDSwAAAAADAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
 	}
-	if vsq3.VSTrack.MusicalPart.Comment.Data == "" {
-		vsq3.VSTrack.MusicalPart.Comment.Data = "New Musical Part"
+}
+
+func (track *VSTrack) normalize() {
+	if track.TrackName.Data == "" {
+		track.TrackName.Data = "Track"
 	}
-	if vsq3.VSTrack.MusicalPart.StylePlugin.StylePluginID.Data == "" {
-		vsq3.VSTrack.MusicalPart.StylePlugin.StylePluginID.Data = "ACA9C502-A04B-42b5-B2EB-5CEA36D16FCE"
+	if track.Comment.Data == "" {
+		track.Comment.Data = "Track"
 	}
-	if vsq3.VSTrack.MusicalPart.StylePlugin.StylePluginName.Data == "" {
-		vsq3.VSTrack.MusicalPart.StylePlugin.StylePluginName.Data = "VOCALOID2 Compatible Style"
+	for _, part := range track.MusicalPart {
+		part.normalize()
 	}
-	if vsq3.VSTrack.MusicalPart.StylePlugin.Version.Data == "" {
-		vsq3.VSTrack.MusicalPart.StylePlugin.Version.Data = "3.0.0.1"
+}
+
+func (part *MusicalPart) normalize() {
+	if part.PartName.Data == "" {
+		part.PartName.Data = "NewPart"
 	}
-	if vsq3.AUX.AUXID.Data == "" {
-		vsq3.AUX.AUXID.Data = "AUX_VST_HOST_CHUNK_INFO"
+	if part.Comment.Data == "" {
+		part.Comment.Data = "New Musical Part"
 	}
-	if vsq3.AUX.Content.Data == "" {
-		vsq3.AUX.Content.Data = "VlNDSwAAAAADAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	if part.StylePlugin.StylePluginID.Data == "" {
+		part.StylePlugin.StylePluginID.Data = "ACA9C502-A04B-42b5-B2EB-5CEA36D16FCE"
+	}
+	if part.StylePlugin.StylePluginName.Data == "" {
+		part.StylePlugin.StylePluginName.Data = "VOCALOID2 Compatible Style"
+	}
+	if part.StylePlugin.Version.Data == "" {
+		part.StylePlugin.Version.Data = "3.0.0.1"
 	}
 }
 
@@ -394,19 +643,75 @@ var phonemes = map[string]string{
 	"でょ": "d' o", "びょ": "b' o", "ぴょ": "p' o",
 }
 
-func (vsq3 *VSQ3) AddNote(velocity, beginTick, endTick, note int, lyrics, phnms string) {
+// HasPhonemes は、歌詞 lyrics に対応する発音記号が定義されているかどうかを返します。
+// 定義されていない歌詞のノートは、発音記号を固定せずに出力されます。
+func HasPhonemes(lyrics string) bool {
+	_, ok := phonemes[lyrics]
+	return ok
+}
+
+// devoicedVowels は、無声化した母音の発音記号です。
+var devoicedVowels = map[string]string{
+	"i": "i_0",
+	"M": "M_0",
+}
+
+// DevoicedPhonemes は、歌詞 lyrics の母音を無声化した発音記号を返します。
+// 母音が無声化しうるもの（/i/ /u/）でない場合は false を返します。
+func DevoicedPhonemes(lyrics string) (string, bool) {
+	p := strings.Fields(phonemes[lyrics])
+	if len(p) == 0 {
+		return "", false
+	}
+	v, ok := devoicedVowels[p[len(p)-1]]
+	if !ok {
+		return "", false
+	}
+	p[len(p)-1] = v
+	return strings.Join(p, " "), true
+}
+
+// longVowelLyrics は、直前のノートの母音を伸ばすノートの歌詞です。
+const longVowelLyrics = "ー"
+
+// lastVowelPhoneme は、最後に追加したノートの末尾の発音記号を返します。
+func (track *VSTrack) lastVowelPhoneme() string {
+	part := track.lastNotePart()
+	if part == nil {
+		return ""
+	}
+	p := strings.Fields(part.Note[len(part.Note)-1].Phnms.Data)
+	if len(p) == 0 || p[len(p)-1] == "Sil" {
+		return ""
+	}
+	return p[len(p)-1]
+}
+
+// AddNote は、ノートを追加します。phnms を省略すると、歌詞 lyrics に対応する発音記号を設定します。
+// 歌詞が "ー" のノートには、直前のノートの母音の発音記号を設定します。
+// 英語ライブラリのトラックでは、日本語の発音記号を英語ライブラリの発音記号に変換して設定します。
+func (track *VSTrack) AddNote(velocity, beginTick, endTick, note int, lyrics, phnms string) {
 	phnmsLock := 1
-	if phnms == "" {
-		if p, ok := phonemes[lyrics]; ok {
-			phnms = p
-		} else {
-			phnmsLock = 0
-			phnms = "4 a"
+	if phnms == "" && lyrics == longVowelLyrics {
+		// 直前のノートの発音記号は変換済み
+		phnms = track.lastVowelPhoneme()
+	} else {
+		if phnms == "" {
+			if p, ok := phonemes[lyrics]; ok {
+				phnms = p
+			} else {
+				phnmsLock = 0
+				phnms = "4 a"
+			}
+		}
+		if track.isEnglish() {
+			phnms = toEnglishPhonemes(phnms)
 		}
 	}
-	vsq3.LimitLastNote(beginTick)
-	vsq3.VSTrack.MusicalPart.Note = append(vsq3.VSTrack.MusicalPart.Note, Note{
-		PosTick:  beginTick,
+	track.LimitLastNote(beginTick)
+	part := track.PartAt(beginTick)
+	n := Note{
+		PosTick:  beginTick - part.BeginTick(),
 		DurTick:  endTick - beginTick,
 		NoteNum:  note,
 		Velocity: velocity,
@@ -423,31 +728,76 @@ func (vsq3 *VSQ3) AddNote(velocity, beginTick, endTick, note int, lyrics, phnms
 			{ID: "vibLen", Value: 0},
 			{ID: "vibType", Value: 0},
 		},
-	})
-	vsq3.noteCount++
+	}
+	for _, a := range track.noteStyle {
+		n.setStyle(a.ID, a.Value)
+	}
+	part.Note = append(part.Note, n)
+	track.noteCount++
 }
 
-func (vsq3 *VSQ3) ExtendLastNote(toTick, ifAfterTick int) bool {
-	part := vsq3.VSTrack.MusicalPart
-	n := len(part.Note)
-	if n < 1 {
+func (track *VSTrack) ExtendLastNote(toTick, ifAfterTick int) bool {
+	part := track.lastNotePart()
+	if part == nil {
 		return false
 	}
-	tail := part.Note[n-1].PosTick + part.Note[n-1].DurTick
+	n := len(part.Note)
+	tail := part.BeginTick() + part.Note[n-1].PosTick + part.Note[n-1].DurTick
 	if tail < ifAfterTick {
 		return false
 	}
-	part.Note[n-1].DurTick = toTick - part.Note[n-1].PosTick
+	part.Note[n-1].DurTick = toTick - part.BeginTick() - part.Note[n-1].PosTick
 	return true
 }
 
-func (vsq3 *VSQ3) LimitLastNote(toTick int) bool {
-	part := vsq3.VSTrack.MusicalPart
-	n := len(part.Note)
-	if n < 1 {
+// noteStyleIDs は、ノートのスタイルとして指定可能な属性の一覧です。
+var noteStyleIDs = []string{"accent", "bendDep", "bendLen", "decay", "fallPort", "opening", "risePort", "vibLen", "vibType"}
+
+// IsNoteStyle は、id がノートのスタイルの属性として定義されているかどうかを返します。
+func IsNoteStyle(id string) bool {
+	for _, s := range noteStyleIDs {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SetNoteStyle は、以降に AddNote で追加するノートのスタイルの既定値を設定します。
+func (track *VSTrack) SetNoteStyle(id string, value int) {
+	for i := range track.noteStyle {
+		if track.noteStyle[i].ID == id {
+			track.noteStyle[i].Value = value
+			return
+		}
+	}
+	track.noteStyle = append(track.noteStyle, Attr{ID: id, Value: value})
+}
+
+// SetLastNoteVibrato は、最後に追加したノートにビブラートを設定します。
+// length はノート長に対するビブラート区間の割合（%）、depth と rate は 0〜127 の値です。
+func (track *VSTrack) SetLastNoteVibrato(length, typ, depth, rate int) bool {
+	part := track.lastNotePart()
+	if part == nil {
 		return false
 	}
-	l := toTick - part.Note[n-1].PosTick
+	note := &part.Note[len(part.Note)-1]
+	note.setStyle("vibLen", length)
+	note.setStyle("vibType", typ)
+	note.NoteSeq = []SeqAttr{
+		{ID: "vibDep", Elem: []SeqElem{{PosNrm: 0, Elv: depth}}},
+		{ID: "vibRate", Elem: []SeqElem{{PosNrm: 0, Elv: rate}}},
+	}
+	return true
+}
+
+func (track *VSTrack) LimitLastNote(toTick int) bool {
+	part := track.lastNotePart()
+	if part == nil {
+		return false
+	}
+	n := len(part.Note)
+	l := toTick - part.BeginTick() - part.Note[n-1].PosTick
 	if part.Note[n-1].DurTick < l {
 		return false
 	}
@@ -455,13 +805,22 @@ func (vsq3 *VSQ3) LimitLastNote(toTick int) bool {
 	return true
 }
 
+func (track *VSTrack) NoteCount() int {
+	return track.noteCount
+}
+
 func (vsq3 *VSQ3) NoteCount() int {
-	return vsq3.noteCount
+	n := 0
+	for _, track := range vsq3.VSTrack {
+		n += track.NoteCount()
+	}
+	return n
 }
 
-func (vsq3 *VSQ3) AddMCtrl(tick int, id string, value int) {
-	vsq3.VSTrack.MusicalPart.MCtrl = append(vsq3.VSTrack.MusicalPart.MCtrl, MCtrl{
-		PosTick: tick,
+func (track *VSTrack) AddMCtrl(tick int, id string, value int) {
+	part := track.PartAt(tick)
+	part.MCtrl = append(part.MCtrl, MCtrl{
+		PosTick: tick - part.BeginTick(),
 		Attr: []Attr{{
 			ID:    id,
 			Value: value,
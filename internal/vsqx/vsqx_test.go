@@ -0,0 +1,75 @@
+package vsqx
+
+import (
+	"testing"
+)
+
+func TestFitParts(t *testing.T) {
+	tests := []struct {
+		name       string
+		split      int
+		notes      [][2]int // 開始位置, 終了位置
+		wantPlay   []int
+		wantLength [][]int
+	}{
+		{
+			name:       "single part",
+			notes:      [][2]int{{0, 480}, {480, 960}},
+			wantPlay:   []int{960},
+			wantLength: [][]int{{480, 480}},
+		},
+		{
+			name:       "note within part",
+			split:      960,
+			notes:      [][2]int{{0, 480}, {1200, 1680}},
+			wantPlay:   []int{480, 720},
+			wantLength: [][]int{{480}, {480}},
+		},
+		{
+			name:       "note crossing part boundary",
+			split:      960,
+			notes:      [][2]int{{0, 480}, {800, 1100}, {1100, 1580}},
+			wantPlay:   []int{960, 620},
+			wantLength: [][]int{{480, 160}, {480}},
+		},
+		{
+			name:       "empty part is removed",
+			split:      960,
+			notes:      [][2]int{{0, 1500}},
+			wantPlay:   []int{1500},
+			wantLength: [][]int{{1500}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track := New("", 480, 120.0).VSTrack[0]
+			if 0 < tt.split {
+				track.SplitPart(tt.split)
+			}
+			for _, n := range tt.notes {
+				track.AddNote(64, n[0], n[1], 60, "あ", "")
+			}
+			track.FitParts()
+			if len(track.MusicalPart) != len(tt.wantPlay) {
+				t.Fatalf("len(MusicalPart) = %d, want %d", len(track.MusicalPart), len(tt.wantPlay))
+			}
+			for i, part := range track.MusicalPart {
+				if part.PlayTime != tt.wantPlay[i] {
+					t.Errorf("part %d: PlayTime = %d, want %d", i, part.PlayTime, tt.wantPlay[i])
+				}
+				if i+1 < len(track.MusicalPart) && track.MusicalPart[i+1].BeginTick() < part.EndTick() {
+					t.Errorf("part %d overlaps the next part", i)
+				}
+				if len(part.Note) != len(tt.wantLength[i]) {
+					t.Errorf("part %d: len(Note) = %d, want %d", i, len(part.Note), len(tt.wantLength[i]))
+					continue
+				}
+				for k, note := range part.Note {
+					if note.DurTick != tt.wantLength[i][k] {
+						t.Errorf("part %d note %d: DurTick = %d, want %d", i, k, note.DurTick, tt.wantLength[i][k])
+					}
+				}
+			}
+		})
+	}
+}
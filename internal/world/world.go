@@ -3,14 +3,34 @@ package world
 /*
 #cgo LDFLAGS: -L../../cmodules/world/build -lworld -lstdc++ -lm
 #cgo CFLAGS: -I../../cmodules/world/src
+#include <stdlib.h>
 #include "world/harvest.h"
+#include "world/cheaptrick.h"
 extern void Harvest(const double *x, int x_length, int fs, const HarvestOption *option, double *temporal_positions, double *f0);
+
+static double** _alloc_spectrogram(int f0_length, int bins) {
+	double** p = (double**)malloc(sizeof(double*) * f0_length);
+	for (int i = 0; i < f0_length; i++) {
+		p[i] = (double*)malloc(sizeof(double) * bins);
+	}
+	return p;
+}
+static void _free_spectrogram(double** p, int f0_length) {
+	for (int i = 0; i < f0_length; i++) {
+		free(p[i]);
+	}
+	free(p);
+}
 */
 import "C"
 import (
 	"math"
+	"unsafe"
 )
 
+// cheapTrickBlock は、CheapTrickMean で一度に推定するフレーム数です。
+const cheapTrickBlock = 1024
+
 func Harvest(x []float64, fs int, framePeriod float64) []float64 {
 	n := len(x)
 	m := n / int(math.Floor(float64(fs)*framePeriod))
@@ -31,3 +51,58 @@ func Harvest(x []float64, fs int, framePeriod float64) []float64 {
 	)
 	return f0
 }
+
+// CheapTrickMean は、音声波形と基本周波数からスペクトル包絡を推定し、全フレームの平均を返します。
+// 戻り値は平均のパワースペクトル（FFTサイズ/2+1 点）と、そのFFTサイズです。
+// メモリ使用量を抑えるため、cheapTrickBlock フレームずつ推定して積算します。
+func CheapTrickMean(x []float64, fs int, framePeriod float64, f0 []float64) ([]float64, int) {
+	m := len(f0)
+	tmppos := make([]float64, m)
+	for i := range tmppos {
+		tmppos[i] = float64(i) * framePeriod
+	}
+	var opts C.CheapTrickOption
+	C.InitializeCheapTrickOption(C.int(fs), &opts)
+	opts.f0_floor = C.double(71.0)
+	opts.fft_size = C.GetFFTSizeForCheapTrick(C.int(fs), &opts)
+	fftSize := int(opts.fft_size)
+	bins := fftSize/2 + 1
+	result := make([]float64, bins)
+	if m == 0 {
+		return result, fftSize
+	}
+
+	block := cheapTrickBlock
+	if m < block {
+		block = m
+	}
+	sp := C._alloc_spectrogram(C.int(block), C.int(bins))
+	defer C._free_spectrogram(sp, C.int(block))
+	rows := (*[1 << 28]*C.double)(unsafe.Pointer(sp))[:block:block]
+	for begin := 0; begin < m; begin += block {
+		n := block
+		if m-begin < n {
+			n = m - begin
+		}
+		C.CheapTrick(
+			(*C.double)(&x[0]),
+			C.int(len(x)),
+			C.int(fs),
+			(*C.double)(&tmppos[begin]),
+			(*C.double)(&f0[begin]),
+			C.int(n),
+			&opts,
+			sp,
+		)
+		for _, p := range rows[:n] {
+			row := (*[1 << 28]C.double)(unsafe.Pointer(p))[:bins:bins]
+			for j, v := range row {
+				result[j] += float64(v)
+			}
+		}
+	}
+	for j := range result {
+		result[j] /= float64(m)
+	}
+	return result, fftSize
+}